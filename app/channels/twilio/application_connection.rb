@@ -1,6 +1,6 @@
 module Twilio
   class ApplicationConnection < ActionCable::Connection::Base
-    identified_by :call_sid, :stream_sid
+    identified_by :call_sid, :stream_sid, :tenant
 
     def connect
       raise "Must not be called; AnyCable server should perform authentication"
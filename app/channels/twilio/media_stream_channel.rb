@@ -9,19 +9,15 @@ module Twilio
 
       self.ai_voice = AIService::VOICES.sample
 
-      greeting =
-        if OpenAIConfig.realtime_enabled?
-          "Hi, I'm #{ai_voice.humanize}. " \
-          "I can tell you about your planned tasks and " \
-          "help to you manage them. What would you like to do?"
-        else
-          "Hi, I'm #{ai_voice.humanize}. " \
-          "Press 1 to check tasks for today. " \
-          "Press 2 to check tasks for tomorrow. " \
-          "Press 3 to check tasks for this week."
-        end
-
-      transmit_message(:greeting, greeting)
+      # The realtime agent greets the caller itself (see #configure_openai)
+      return if OpenAIConfig.realtime_enabled?
+
+      say(
+        "Hi, I'm #{ai_voice.humanize}. " \
+        "Press 1 to check tasks for today. " \
+        "Press 2 to check tasks for tomorrow. " \
+        "Press 3 to check tasks for this week."
+      )
     end
 
     def handle_dtmf(data)
@@ -43,7 +39,7 @@ module Twilio
         "You don't have any tasks for #{period}"
       end
 
-      transmit_message(:"dtmf_response_#{digit}", phrase)
+      say(phrase)
     end
 
     # OpenAI tools
@@ -108,7 +104,10 @@ module Twilio
         }
       ].to_json
 
-      reply_with("openai.configuration", {api_key:, voice:, prompt:, tools:})
+      greeting = "Introduce yourself as #{ai_voice.humanize}, tell the caller that you can tell " \
+        "about their planned tasks and help to manage them, and ask what they would like to do."
+
+      reply_with("openai.configuration", {api_key:, voice:, prompt:, tools:, greeting:})
     end
 
     def handle_transcript(data)
@@ -163,29 +162,12 @@ module Twilio
 
     private
 
-    def transmit_message(id, message)
-      # This is an example of how you can send audio to the media stream from the
-      # web app.
-      transmit({
-        event: "media",
-        streamSid: stream_sid,
-        media: {
-          payload: ai.generate_twilio_audio(message, voice: ai_voice),
-        }
-      })
+    # AnyCable server speaks the text via the realtime agent
+    # (or via the TTS backend if there is no agent in the call)
+    def say(message)
+      transmit({event: "openai.say", data: {text: message, voice: ai_voice}})
 
       broadcast_log "> #{message}"
-
-      # Mark message is required to keep track of the played audio.
-      # Twilio will send its mark event with the same name as soon as the audio
-      # has been played.
-      transmit({
-        event: "mark",
-        streamSid: stream_sid,
-        mark: {
-          name: id
-        }
-      })
     end
 
     def broadcast_call_status(status)
@@ -201,7 +183,5 @@ module Twilio
     def broadcast_log(...) = twilio.broadcast_logs(call_sid, ...)
 
     def twilio = @twilio ||= TwilioService.new
-
-    def ai = @ai ||= AIService.new
   end
 end
@@ -0,0 +1,14 @@
+module Twilio
+  # Resolves tenants for the AnyCable server (when started with `--tenants=rpc`).
+  # The channel is never subscribed to, the server performs the lookup action directly.
+  class TenantChannel < ApplicationChannel
+    def lookup(data)
+      account_sid = data["account_sid"]
+
+      # Single-tenant setup: only the configured account is served
+      return unless account_sid.present? && account_sid == TwilioConfig.account_sid
+
+      reply_with("tenant", {id: "default", auth_token: TwilioConfig.auth_token})
+    end
+  end
+end
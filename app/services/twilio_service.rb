@@ -43,6 +43,11 @@ class TwilioService
     )
   end
 
+  # Send a command to the live call (handled by the AnyCable server, see cable/README.md)
+  def send_call_command(call_sid, event, data = {})
+    ActionCable.server.broadcast("call_#{call_sid}", {event:, data:})
+  end
+
   private
 
   def client = @client ||= Twilio::REST::Client.new(config.account_sid, config.auth_token)
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/palkan/twilio-ai-cable/internal/g711"
+	"github.com/palkan/twilio-ai-cable/internal/sim"
+	"github.com/palkan/twilio-ai-cable/internal/wav"
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+	"github.com/palkan/twilio-ai-cable/pkg/version"
+)
+
+func main() {
+	app := &cli.App{
+		Name:    "twilio-sim",
+		Usage:   "Replay a WAV file as a Twilio Media Stream call",
+		Version: version.Version(),
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "url",
+				Usage: "Twilio Media Streams endpoint",
+				Value: "ws://localhost:8080/twilio",
+			},
+			&cli.StringFlag{
+				Name:     "input",
+				Aliases:  []string{"i"},
+				Usage:    "WAV file with the caller's audio (16-bit PCM or μ-law)",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "WAV file to record the audio played to the caller",
+			},
+			&cli.StringFlag{
+				Name:  "dtmf",
+				Usage: `DTMF schedule, e.g., "2s:1,5s:#"`,
+			},
+			&cli.DurationFlag{
+				Name:  "tail",
+				Usage: "How long to keep the call after the input audio ends",
+				Value: 5 * time.Second,
+			},
+			&cli.StringFlag{
+				Name:    "account_sid",
+				EnvVars: []string{"TWILIO_ACCOUNT_SID"},
+				Value:   "AC00000000000000000000000000000000",
+			},
+			&cli.StringFlag{
+				Name:    "auth_token",
+				Usage:   "Sign the connection request (X-Twilio-Signature) with the auth token",
+				EnvVars: []string{"TWILIO_AUTH_TOKEN"},
+			},
+			&cli.StringFlag{
+				Name: "call_sid",
+			},
+			&cli.StringFlag{
+				Name: "stream_sid",
+			},
+			&cli.StringSliceFlag{
+				Name:  "param",
+				Usage: `Custom stream parameter (as <Parameter> in TwiML), e.g., "FirstName=Jane"`,
+			},
+			&cli.BoolFlag{
+				Name:  "debug",
+				Usage: "Enable debug logging",
+			},
+		},
+		Action: run,
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(c *cli.Context) error {
+	level := slog.LevelInfo
+
+	if c.Bool("debug") {
+		level = slog.LevelDebug
+	}
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+
+	input, err := os.Open(c.String("input"))
+
+	if err != nil {
+		return err
+	}
+
+	format, data, err := wav.Read(input)
+	input.Close()
+
+	if err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+
+	audio, err := wav.ToMulaw(format, data)
+
+	if err != nil {
+		return err
+	}
+
+	dtmf, err := sim.ParseDTMFSchedule(c.String("dtmf"))
+
+	if err != nil {
+		return err
+	}
+
+	params := make(map[string]string)
+
+	for _, param := range c.StringSlice("param") {
+		key, value, ok := strings.Cut(param, "=")
+
+		if !ok {
+			return fmt.Errorf("malformed parameter: %s", param)
+		}
+
+		params[key] = value
+	}
+
+	conf := &sim.Config{
+		URL:        c.String("url"),
+		AccountSID: c.String("account_sid"),
+		CallSID:    c.String("call_sid"),
+		StreamSID:  c.String("stream_sid"),
+		Headers:    http.Header{},
+		Parameters: params,
+		Audio:      audio,
+		DTMF:       dtmf,
+		Tail:       c.Duration("tail"),
+	}
+
+	if token := c.String("auth_token"); token != "" {
+		conf.Headers.Set(twilio.SignatureHeader, twilio.ComputeSignature(token, conf.URL, nil))
+	}
+
+	if conf.CallSID == "" {
+		conf.CallSID = sim.NewSID("CA")
+	}
+
+	if conf.StreamSID == "" {
+		conf.StreamSID = sim.NewSID("MZ")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	played, err := sim.NewSimulator(conf, log).Run(ctx)
+
+	if output := c.String("output"); output != "" && len(played) > 0 {
+		f, ferr := os.Create(output)
+
+		if ferr != nil {
+			return ferr
+		}
+
+		defer f.Close()
+
+		if werr := wav.Write(f, wav.PCMFormat(1, 8000), g711.DecodeUlaw(played)); werr != nil {
+			return werr
+		}
+
+		log.Info("recording saved", "path", output, "duration", time.Duration(len(played))*time.Second/8000)
+	}
+
+	return err
+}
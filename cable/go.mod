@@ -5,8 +5,11 @@ go 1.23
 require (
 	github.com/anycable/anycable-go v1.5.6
 	github.com/gorilla/websocket v1.5.3
+	github.com/joomcode/errorx v1.1.1
+	github.com/matoous/go-nanoid v1.5.0
 	github.com/stretchr/testify v1.9.0
 	github.com/urfave/cli/v2 v2.27.4
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -23,10 +26,8 @@ require (
 	github.com/google/gops v0.3.28 // indirect
 	github.com/hofstadter-io/cinful v1.0.0 // indirect
 	github.com/jhump/protoreflect v1.17.0 // indirect
-	github.com/joomcode/errorx v1.1.1 // indirect
 	github.com/klauspost/compress v1.17.10 // indirect
 	github.com/lmittmann/tint v1.0.5 // indirect
-	github.com/matoous/go-nanoid v1.5.0 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/minio/highwayhash v1.0.3 // indirect
 	github.com/mitchellh/go-mruby v0.0.0-20200315023956-207cedc21542 // indirect
@@ -53,5 +54,4 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240930140551-af27646dc61f // indirect
 	google.golang.org/grpc v1.67.1 // indirect
 	google.golang.org/protobuf v1.34.2 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
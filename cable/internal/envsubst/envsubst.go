@@ -0,0 +1,17 @@
+// Package envsubst expands environment variables references in config files.
+package envsubst
+
+import (
+	"os"
+	"regexp"
+)
+
+var reference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Expand replaces ${VAR} references with the values of the environment variables (empty if not set).
+// Unlike os.ExpandEnv, other dollar signs (e.g., in secrets) are left intact.
+func Expand(src string) string {
+	return reference.ReplaceAllStringFunc(src, func(ref string) string {
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
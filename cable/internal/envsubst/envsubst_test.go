@@ -0,0 +1,17 @@
+package envsubst
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpand(t *testing.T) {
+	t.Setenv("ENVSUBST_TOKEN", "secret")
+
+	assert.Equal(t, "token: secret", Expand("token: ${ENVSUBST_TOKEN}"))
+	assert.Equal(t, "token: ", Expand("token: ${ENVSUBST_MISSING}"))
+	assert.Equal(t, "token: pa$$w0rd$", Expand("token: pa$$w0rd$"))
+	assert.Equal(t, "token: $ENVSUBST_TOKEN", Expand("token: $ENVSUBST_TOKEN"))
+	assert.Equal(t, "token: ${not a var}", Expand("token: ${not a var}"))
+}
@@ -0,0 +1,321 @@
+// Package fake_openai implements a minimal OpenAI realtime API server
+// to be used in tests instead of wss://api.openai.com.
+package fake_openai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Handler is called for every client event of the matching type
+type Handler = func(c *Conn, msg []byte)
+
+// Server is a scriptable fake realtime server.
+// It accepts WebSocket connections, records all client events and
+// allows sending arbitrary server events back.
+type Server struct {
+	srv *httptest.Server
+
+	conns    []*Conn
+	connCh   chan *Conn
+	handlers map[string]Handler
+
+	mu sync.Mutex
+}
+
+// Conn represents a single client connection to the fake server
+type Conn struct {
+	// Request headers (e.g., to verify authorization)
+	Header http.Header
+	// Query parameters (e.g., to verify the model)
+	Query map[string][]string
+
+	ws       *websocket.Conn
+	received []json.RawMessage
+	notify   chan struct{}
+	seq      int
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+}
+
+var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+// NewServer starts a new fake server listening on a random local port
+func NewServer() *Server {
+	s := &Server{
+		connCh:   make(chan *Conn, 16),
+		handlers: make(map[string]Handler),
+	}
+
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveWS))
+
+	return s
+}
+
+// URL returns the WebSocket URL of the server (to be used as agent.Config.URL)
+func (s *Server) URL() string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http")
+}
+
+// Close closes all the connections and shuts down the server
+func (s *Server) Close() {
+	s.mu.Lock()
+	conns := s.conns
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		c.Close()
+	}
+
+	s.srv.Close()
+}
+
+// On registers a handler for the client event type (e.g., "response.create").
+// Handlers are used to script the server behaviour.
+func (s *Server) On(eventType string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[eventType] = handler
+}
+
+// NextConn waits for a new client connection
+func (s *Server) NextConn(timeout time.Duration) (*Conn, error) {
+	select {
+	case c := <-s.connCh:
+		return c, nil
+	case <-time.After(timeout):
+		return nil, errors.New("no connection received")
+	}
+}
+
+func (s *Server) serveWS(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrader.Upgrade(w, r, nil)
+
+	if err != nil {
+		return
+	}
+
+	c := &Conn{
+		Header: r.Header,
+		Query:  r.URL.Query(),
+		ws:     ws,
+		notify: make(chan struct{}, 1),
+	}
+
+	s.mu.Lock()
+	s.conns = append(s.conns, c)
+	s.mu.Unlock()
+
+	s.connCh <- c
+
+	_ = c.Send(map[string]interface{}{
+		"type":    "session.created",
+		"session": map[string]string{"id": "sess_fake", "object": "realtime.session"},
+	})
+
+	for {
+		_, msg, err := ws.ReadMessage()
+
+		if err != nil {
+			return
+		}
+
+		var typed struct {
+			Type string `json:"type"`
+		}
+
+		_ = json.Unmarshal(msg, &typed)
+
+		c.mu.Lock()
+		c.received = append(c.received, msg)
+		c.mu.Unlock()
+
+		select {
+		case c.notify <- struct{}{}:
+		default:
+		}
+
+		s.mu.Lock()
+		handler := s.handlers[typed.Type]
+		s.mu.Unlock()
+
+		if handler != nil {
+			handler(c, msg)
+		}
+	}
+}
+
+// Received returns all the client events received so far
+func (c *Conn) Received() []json.RawMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]json.RawMessage(nil), c.received...)
+}
+
+// WaitFor waits for the client event of the specified type and returns it.
+// Events received before the call are taken into account, too.
+func (c *Conn) WaitFor(eventType string, timeout time.Duration) (json.RawMessage, error) {
+	deadline := time.After(timeout)
+	checked := 0
+
+	for {
+		received := c.Received()
+
+		for _, msg := range received[checked:] {
+			var typed struct {
+				Type string `json:"type"`
+			}
+
+			_ = json.Unmarshal(msg, &typed)
+
+			if typed.Type == eventType {
+				return msg, nil
+			}
+		}
+
+		checked = len(received)
+
+		select {
+		case <-c.notify:
+		case <-deadline:
+			return nil, fmt.Errorf("event not received: %s", eventType)
+		}
+	}
+}
+
+// Send sends an arbitrary server event
+func (c *Conn) Send(event interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.ws.WriteJSON(event)
+}
+
+// Close closes the connection abnormally (without a close frame)
+func (c *Conn) Close() {
+	c.ws.Close()
+}
+
+// SendSpeechStarted emulates server VAD detecting the caller's speech
+func (c *Conn) SendSpeechStarted(itemID string) error {
+	return c.Send(map[string]interface{}{
+		"event_id":       c.nextEventID(),
+		"type":           "input_audio_buffer.speech_started",
+		"item_id":        itemID,
+		"audio_start_ms": 0,
+	})
+}
+
+// SendResponseCreated notifies the client about a new response
+func (c *Conn) SendResponseCreated(responseID string) error {
+	return c.Send(map[string]interface{}{
+		"event_id": c.nextEventID(),
+		"type":     "response.created",
+		"response": map[string]interface{}{"id": responseID, "object": "realtime.response", "status": "in_progress"},
+	})
+}
+
+// SendResponseDone completes the response with the specified status and usage
+func (c *Conn) SendResponseDone(responseID string, status string, usage map[string]interface{}) error {
+	response := map[string]interface{}{"id": responseID, "object": "realtime.response", "status": status}
+
+	if usage != nil {
+		response["usage"] = usage
+	}
+
+	return c.Send(map[string]interface{}{
+		"event_id": c.nextEventID(),
+		"type":     "response.done",
+		"response": response,
+	})
+}
+
+// SendAudioDelta sends a chunk of assistant's audio (raw μ-law bytes)
+func (c *Conn) SendAudioDelta(responseID string, itemID string, audio []byte) error {
+	return c.Send(map[string]interface{}{
+		"event_id":      c.nextEventID(),
+		"type":          "response.audio.delta",
+		"response_id":   responseID,
+		"item_id":       itemID,
+		"output_index":  0,
+		"content_index": 0,
+		"delta":         base64.StdEncoding.EncodeToString(audio),
+	})
+}
+
+// SendAudioTranscript sends the complete assistant's transcript for the item
+func (c *Conn) SendAudioTranscript(responseID string, itemID string, transcript string) error {
+	return c.Send(map[string]interface{}{
+		"event_id":      c.nextEventID(),
+		"type":          "response.audio_transcript.done",
+		"response_id":   responseID,
+		"item_id":       itemID,
+		"output_index":  0,
+		"content_index": 0,
+		"transcript":    transcript,
+	})
+}
+
+// SendInputTranscript sends the caller's speech transcript
+func (c *Conn) SendInputTranscript(itemID string, transcript string) error {
+	return c.Send(map[string]interface{}{
+		"event_id":      c.nextEventID(),
+		"type":          "conversation.item.input_audio_transcription.completed",
+		"item_id":       itemID,
+		"content_index": 0,
+		"transcript":    transcript,
+	})
+}
+
+// SendFunctionCall emulates the model calling a function
+func (c *Conn) SendFunctionCall(responseID string, itemID string, callID string, name string, args string) error {
+	return c.Send(map[string]interface{}{
+		"event_id":     c.nextEventID(),
+		"type":         "response.output_item.done",
+		"response_id":  responseID,
+		"output_index": 0,
+		"item": map[string]interface{}{
+			"id":        itemID,
+			"object":    "realtime.item",
+			"type":      "function_call",
+			"status":    "completed",
+			"call_id":   callID,
+			"name":      name,
+			"arguments": args,
+		},
+	})
+}
+
+// SendError sends an error event
+func (c *Conn) SendError(code string, message string) error {
+	return c.Send(map[string]interface{}{
+		"event_id": c.nextEventID(),
+		"type":     "error",
+		"error": map[string]interface{}{
+			"type":    "invalid_request_error",
+			"code":    code,
+			"message": message,
+		},
+	})
+}
+
+func (c *Conn) nextEventID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+
+	return fmt.Sprintf("event_%d", c.seq)
+}
@@ -11,10 +11,12 @@ import (
 
 const (
 	welcomeMessage = "{\"type\":\"welcome\"}"
+	responseState  = "anycable_response"
 )
 
 type Controller struct {
-	log *slog.Logger
+	scenario *Scenario
+	log      *slog.Logger
 }
 
 var _ node.Controller = (*Controller)(nil)
@@ -23,6 +25,11 @@ func NewController(l *slog.Logger) *Controller {
 	return &Controller{log: l.With("context", "fake_rpc")}
 }
 
+// NewControllerWithScenario creates a controller responding to actions according to the scenario
+func NewControllerWithScenario(sc *Scenario, l *slog.Logger) *Controller {
+	return &Controller{scenario: sc, log: l.With("context", "fake_rpc")}
+}
+
 // Start is no-op
 func (c *Controller) Start() error {
 	c.log.Warn("Using fake RPC controller")
@@ -70,12 +77,24 @@ func (c *Controller) Perform(sid string, env *common.SessionEnv, id string, chan
 		return nil, err
 	}
 
-	action := payload["action"].(string)
+	action, _ := payload["action"].(string)
 
 	c.log.With("sid", sid).Debug("> Performed action", "action", action, "payload", data)
 
 	nextState := make(map[string]string)
 
+	if response := c.scenario.Lookup(action, payload); response != nil {
+		encoded, err := response.Encode()
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.log.With("sid", sid).Debug("< Replied", "action", action, "event", response.Event)
+
+		nextState[responseState] = encoded
+	}
+
 	res = &common.CommandResult{
 		Status:         common.SUCCESS,
 		Disconnect:     false,
@@ -0,0 +1,104 @@
+package fake_rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/palkan/twilio-ai-cable/internal/envsubst"
+)
+
+// Response is a canned RPC response (see Twilio::ApplicationChannel#reply_with)
+type Response struct {
+	Event string      `yaml:"event" json:"event"`
+	Data  interface{} `yaml:"data" json:"data"`
+}
+
+// Scenario maps channel actions to canned responses.
+//
+// Example (YAML or JSON):
+//
+//	actions:
+//	  configure_openai:
+//	    event: openai.configuration
+//	    data:
+//	      api_key: ${OPENAI_API_KEY}
+//	functions:
+//	  get_tasks:
+//	    event: openai.function_call_result
+//	    data:
+//	      todos: []
+//	dtmf:
+//	  "1":
+//	    event: openai.say
+//	    data:
+//	      text: "You have no tasks for today"
+type Scenario struct {
+	// Responses for actions by name
+	Actions map[string]*Response `yaml:"actions"`
+	// Responses for handle_function_call by function name
+	Functions map[string]*Response `yaml:"functions"`
+	// Responses for handle_dtmf by digit
+	DTMF map[string]*Response `yaml:"dtmf"`
+}
+
+// LoadScenario reads a scenario from the YAML or JSON file.
+// Environment variables references (e.g., ${OPENAI_API_KEY}) are expanded; other dollar signs are kept as is.
+func LoadScenario(path string) (*Scenario, error) {
+	raw, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseScenario(envsubst.Expand(string(raw)))
+}
+
+// ParseScenario parses a YAML or JSON scenario
+func ParseScenario(src string) (*Scenario, error) {
+	var sc Scenario
+
+	if err := yaml.NewDecoder(strings.NewReader(src)).Decode(&sc); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %w", err)
+	}
+
+	return &sc, nil
+}
+
+// Lookup returns the response for the action performed with the given payload (if any)
+func (sc *Scenario) Lookup(action string, payload map[string]interface{}) *Response {
+	if sc == nil {
+		return nil
+	}
+
+	switch action {
+	case "handle_function_call":
+		if name, ok := payload["name"].(string); ok {
+			if res, ok := sc.Functions[name]; ok {
+				return res
+			}
+		}
+	case "handle_dtmf":
+		if digit, ok := payload["digit"].(string); ok {
+			if res, ok := sc.DTMF[digit]; ok {
+				return res
+			}
+		}
+	}
+
+	return sc.Actions[action]
+}
+
+// Encode returns the response in the format of the channel state
+func (r *Response) Encode() (string, error) {
+	b, err := json.Marshal(r)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,106 @@
+package fake_rpc
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const scenarioYAML = `
+actions:
+  configure_openai:
+    event: openai.configuration
+    data:
+      api_key: sk-test
+      prompt: Be nice
+functions:
+  get_tasks:
+    event: openai.function_call_result
+    data:
+      todos: []
+dtmf:
+  "1":
+    event: openai.say
+    data:
+      text: Hello
+`
+
+func TestControllerPerformWithScenario(t *testing.T) {
+	sc, err := ParseScenario(scenarioYAML)
+	require.NoError(t, err)
+
+	controller := NewControllerWithScenario(sc, slog.Default())
+	env := common.NewSessionEnv("ws://demo.anycable.io/twilio", nil)
+
+	perform := func(data string) map[string]interface{} {
+		res, err := controller.Perform("42", env, "", "", data)
+		require.NoError(t, err)
+
+		raw, ok := res.IState[responseState]
+
+		if !ok {
+			return nil
+		}
+
+		var decoded map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(raw), &decoded))
+
+		return decoded
+	}
+
+	t.Run("action", func(t *testing.T) {
+		res := perform(`{"action":"configure_openai"}`)
+
+		assert.Equal(t, "openai.configuration", res["event"])
+		assert.Equal(t, map[string]interface{}{"api_key": "sk-test", "prompt": "Be nice"}, res["data"])
+	})
+
+	t.Run("function call", func(t *testing.T) {
+		res := perform(`{"action":"handle_function_call","name":"get_tasks","arguments":"{}"}`)
+
+		assert.Equal(t, "openai.function_call_result", res["event"])
+		assert.Equal(t, map[string]interface{}{"todos": []interface{}{}}, res["data"])
+
+		assert.Nil(t, perform(`{"action":"handle_function_call","name":"create_task","arguments":"{}"}`))
+	})
+
+	t.Run("dtmf", func(t *testing.T) {
+		res := perform(`{"action":"handle_dtmf","digit":"1"}`)
+
+		assert.Equal(t, "openai.say", res["event"])
+		assert.Nil(t, perform(`{"action":"handle_dtmf","digit":"2"}`))
+	})
+
+	t.Run("unknown action", func(t *testing.T) {
+		assert.Nil(t, perform(`{"action":"handle_transcript"}`))
+	})
+}
+
+func TestLoadScenario(t *testing.T) {
+	t.Setenv("SCENARIO_TEST_API_KEY", "sk-test")
+
+	path := filepath.Join(t.TempDir(), "scenario.yml")
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+actions:
+  configure_openai:
+    event: openai.configuration
+    data:
+      api_key: ${SCENARIO_TEST_API_KEY}
+      prompt: Tell the caller the price is $5
+`), 0o600))
+
+	sc, err := LoadScenario(path)
+	require.NoError(t, err)
+
+	res := sc.Lookup("configure_openai", nil)
+	require.NotNil(t, res)
+
+	assert.Equal(t, map[string]interface{}{"api_key": "sk-test", "prompt": "Tell the caller the price is $5"}, res.Data)
+}
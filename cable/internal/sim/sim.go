@@ -0,0 +1,381 @@
+// Package sim emulates Twilio Media Streams: it connects to the cable,
+// streams caller's audio and plays back the bot's audio the same way Twilio does.
+package sim
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+const (
+	// 20ms of 8kHz μ-law audio
+	frameSize     = 160
+	frameDuration = 20 * time.Millisecond
+	// μ-law silence
+	silence = 0xff
+)
+
+type DTMF struct {
+	At    time.Duration
+	Digit string
+}
+
+// ParseDTMFSchedule parses schedules in the "<duration>:<digit>,..." format (e.g., "2s:1,4.5s:#").
+// Entries are sorted by time, so they can be specified in any order
+func ParseDTMFSchedule(schedule string) ([]DTMF, error) {
+	var res []DTMF
+
+	for _, part := range strings.Split(schedule, ",") {
+		part = strings.TrimSpace(part)
+
+		if part == "" {
+			continue
+		}
+
+		at, digit, ok := strings.Cut(part, ":")
+
+		if !ok || digit == "" {
+			return nil, fmt.Errorf("malformed DTMF entry: %s", part)
+		}
+
+		d, err := time.ParseDuration(at)
+
+		if err != nil {
+			return nil, fmt.Errorf("malformed DTMF entry: %s", part)
+		}
+
+		res = append(res, DTMF{At: d, Digit: digit})
+	}
+
+	sort.SliceStable(res, func(i, j int) bool { return res[i].At < res[j].At })
+
+	return res, nil
+}
+
+type Config struct {
+	URL        string
+	AccountSID string
+	CallSID    string
+	StreamSID  string
+	// Extra headers to send with the upgrade request
+	Headers http.Header
+	// Custom parameters (as passed via <Parameter> in TwiML)
+	Parameters map[string]string
+	// Caller's audio (8kHz mono μ-law)
+	Audio []byte
+	DTMF  []DTMF
+	// How long to wait for the bot after the audio has been sent
+	Tail time.Duration
+}
+
+// Simulator is a single simulated call
+type Simulator struct {
+	conf *Config
+	log  *slog.Logger
+
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	// Bot's audio waiting to be played and the corresponding marks
+	queue []*playbackEntry
+	// Everything the caller heard
+	played []byte
+	// Stats
+	marks  int
+	clears int
+
+	mu sync.Mutex
+}
+
+type playbackEntry struct {
+	audio []byte
+	mark  string
+}
+
+func NewSimulator(c *Config, l *slog.Logger) *Simulator {
+	return &Simulator{conf: c, log: l.With("context", "sim")}
+}
+
+// Run performs a call and returns the audio played to the caller (8kHz mono μ-law)
+func (sim *Simulator) Run(ctx context.Context) ([]byte, error) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, sim.conf.URL, sim.conf.Headers)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", sim.conf.URL, err)
+	}
+
+	sim.conn = conn
+	defer conn.Close()
+
+	if err := sim.send(twilio.ConnectedMessage{Event: twilio.ConnectedEvent, Protocol: "Call", Version: "1.0.0"}); err != nil {
+		return nil, err
+	}
+
+	var seq int64 = 1
+
+	err = sim.send(twilio.StartMessage{
+		Event:     twilio.StartEvent,
+		StreamSID: sim.conf.StreamSID,
+		Seq:       seq,
+		Start: twilio.StartPayload{
+			AccountSID:       sim.conf.AccountSID,
+			CallSID:          sim.conf.CallSID,
+			StreamSID:        sim.conf.StreamSID,
+			Tracks:           []string{"inbound"},
+			MediaFormat:      &twilio.MediaFormat{Encoding: twilio.MulawEncoding, SampleRate: twilio.MulawSampleRate, Channels: 1},
+			CustomParameters: sim.conf.Parameters,
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sim.log.Info("call started", "call_sid", sim.conf.CallSID, "stream_sid", sim.conf.StreamSID)
+
+	readErr := make(chan error, 1)
+	go func() { readErr <- sim.readMessages() }()
+
+	ticker := time.NewTicker(frameDuration)
+	defer ticker.Stop()
+
+	audio := sim.conf.Audio
+	dtmf := sim.conf.DTMF
+	total := time.Duration(len(audio)/frameSize)*frameDuration + sim.conf.Tail
+
+	var elapsed time.Duration
+
+loop:
+	for elapsed < total {
+		select {
+		case <-ctx.Done():
+			break loop
+		case err := <-readErr:
+			if err != nil {
+				return sim.Played(), err
+			}
+
+			sim.log.Info("connection closed by server")
+			return sim.Played(), nil
+		case <-ticker.C:
+		}
+
+		seq++
+
+		if len(audio) > 0 {
+			n := min(frameSize, len(audio))
+			frame := audio[:n]
+			audio = audio[n:]
+
+			err := sim.send(twilio.MediaMessage{
+				Event:     twilio.MediaEvent,
+				StreamSID: sim.conf.StreamSID,
+				Seq:       seq,
+				Media:     twilio.MediaPayload{Track: "inbound", Payload: base64.StdEncoding.EncodeToString(frame)},
+			})
+
+			if err != nil {
+				return sim.Played(), err
+			}
+		}
+
+		for len(dtmf) > 0 && dtmf[0].At <= elapsed {
+			sim.log.Info("sending DTMF", "digit", dtmf[0].Digit)
+
+			err := sim.send(twilio.DTMFMessage{
+				Event:     twilio.DTMFEvent,
+				StreamSID: sim.conf.StreamSID,
+				Seq:       seq,
+				DTMF:      twilio.DTMFPayload{Track: "inbound_track", Digit: dtmf[0].Digit},
+			})
+
+			if err != nil {
+				return sim.Played(), err
+			}
+
+			dtmf = dtmf[1:]
+		}
+
+		if err := sim.playFrame(); err != nil {
+			return sim.Played(), err
+		}
+
+		elapsed += frameDuration
+	}
+
+	seq++
+
+	err = sim.send(twilio.StopMessage{
+		Event:     twilio.StopEvent,
+		StreamSID: sim.conf.StreamSID,
+		Seq:       seq,
+		Stop:      twilio.StopPayload{AccountSID: sim.conf.AccountSID, StreamSID: sim.conf.StreamSID},
+	})
+
+	sim.mu.Lock()
+	sim.log.Info("call finished", "duration", elapsed, "marks", sim.marks, "clears", sim.clears)
+	sim.mu.Unlock()
+
+	return sim.Played(), err
+}
+
+// Played returns the audio played to the caller so far
+func (sim *Simulator) Played() []byte {
+	sim.mu.Lock()
+	defer sim.mu.Unlock()
+
+	return append([]byte(nil), sim.played...)
+}
+
+func (sim *Simulator) readMessages() error {
+	for {
+		_, raw, err := sim.conn.ReadMessage()
+
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+
+			return err
+		}
+
+		var msg twilio.DecodeMessage
+
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			sim.log.Warn("malformed message received", "msg", string(raw))
+			continue
+		}
+
+		switch msg.Event {
+		case twilio.MediaEvent:
+			audio, err := base64.StdEncoding.DecodeString(msg.Media.Payload)
+
+			if err != nil {
+				sim.log.Warn("malformed media received", "err", err)
+				continue
+			}
+
+			sim.mu.Lock()
+			sim.queue = append(sim.queue, &playbackEntry{audio: audio})
+			sim.mu.Unlock()
+		case twilio.MarkEvent:
+			sim.mu.Lock()
+			sim.queue = append(sim.queue, &playbackEntry{mark: msg.Mark.Name})
+			sim.mu.Unlock()
+		case twilio.ClearEvent:
+			if err := sim.clear(); err != nil {
+				return err
+			}
+		default:
+			sim.log.Debug("unknown message received", "msg", string(raw))
+		}
+	}
+}
+
+// playFrame plays the next 20ms of the bot's audio (or silence)
+// and acknowledges the marks reached
+func (sim *Simulator) playFrame() error {
+	sim.mu.Lock()
+
+	frame := make([]byte, 0, frameSize)
+	var marks []string
+
+	for len(sim.queue) > 0 {
+		entry := sim.queue[0]
+
+		if entry.audio == nil {
+			marks = append(marks, entry.mark)
+			sim.queue = sim.queue[1:]
+			continue
+		}
+
+		if len(frame) == frameSize {
+			break
+		}
+
+		n := min(frameSize-len(frame), len(entry.audio))
+		frame = append(frame, entry.audio[:n]...)
+		entry.audio = entry.audio[n:]
+
+		if len(entry.audio) == 0 {
+			sim.queue = sim.queue[1:]
+		}
+	}
+
+	for len(frame) < frameSize {
+		frame = append(frame, silence)
+	}
+
+	sim.played = append(sim.played, frame...)
+	sim.marks += len(marks)
+	sim.mu.Unlock()
+
+	return sim.sendMarks(marks)
+}
+
+// clear drops all the pending audio and sends back all the pending marks (as Twilio does)
+func (sim *Simulator) clear() error {
+	sim.mu.Lock()
+
+	var marks []string
+
+	for _, entry := range sim.queue {
+		if entry.audio == nil {
+			marks = append(marks, entry.mark)
+		}
+	}
+
+	sim.queue = nil
+	sim.clears++
+	sim.marks += len(marks)
+	sim.mu.Unlock()
+
+	sim.log.Debug("playback cleared", "marks", len(marks))
+
+	return sim.sendMarks(marks)
+}
+
+func (sim *Simulator) sendMarks(marks []string) error {
+	for _, mark := range marks {
+		err := sim.send(twilio.MarkMessage{
+			Event:     twilio.MarkEvent,
+			StreamSID: sim.conf.StreamSID,
+			Mark:      twilio.MarkPayload{Name: mark},
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sim *Simulator) send(msg interface{}) error {
+	sim.writeMu.Lock()
+	defer sim.writeMu.Unlock()
+
+	if err := sim.conn.WriteJSON(msg); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// NewSID generates a random-looking Twilio SID with the specified prefix (e.g., "CA")
+func NewSID(prefix string) string {
+	return prefix + strconv.FormatInt(time.Now().UnixNano(), 16)
+}
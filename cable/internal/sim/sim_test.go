@@ -0,0 +1,169 @@
+package sim
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+func TestParseDTMFSchedule(t *testing.T) {
+	for name, tc := range map[string]struct {
+		schedule string
+		expected []DTMF
+		err      string
+	}{
+		"empty":        {schedule: "", expected: nil},
+		"single":       {schedule: "2s:1", expected: []DTMF{{At: 2 * time.Second, Digit: "1"}}},
+		"multiple":     {schedule: "2s:1, 4.5s:#,", expected: []DTMF{{At: 2 * time.Second, Digit: "1"}, {At: 4500 * time.Millisecond, Digit: "#"}}},
+		"out of order": {schedule: "3s:2,1s:1,3s:3", expected: []DTMF{{At: time.Second, Digit: "1"}, {At: 3 * time.Second, Digit: "2"}, {At: 3 * time.Second, Digit: "3"}}},
+		"no digit":     {schedule: "2s:1,3s:", err: "malformed DTMF entry: 3s:"},
+		"no delimiter": {schedule: "2s", err: "malformed DTMF entry: 2s"},
+		"bad duration": {schedule: "two:1", err: "malformed DTMF entry: two:1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			res, err := ParseDTMFSchedule(tc.schedule)
+
+			if tc.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, tc.err, err.Error())
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, res)
+		})
+	}
+}
+
+func TestSimulatorPlayFrame(t *testing.T) {
+	sim, marks := newTestSimulator(t)
+
+	sim.queue = []*playbackEntry{
+		{audio: audioFrame(200, 1)},
+		{mark: "first"},
+		{audio: audioFrame(100, 2)},
+		{mark: "second"},
+	}
+
+	// The mark is acknowledged only when the audio before it has been played
+	require.NoError(t, sim.playFrame())
+	assertNoMarks(t, marks)
+
+	require.NoError(t, sim.playFrame())
+	assert.Equal(t, "first", receiveMark(t, marks))
+	assert.Equal(t, "second", receiveMark(t, marks))
+
+	// Nothing to play — silence
+	require.NoError(t, sim.playFrame())
+	assertNoMarks(t, marks)
+
+	played := sim.Played()
+
+	require.Len(t, played, 3*frameSize)
+	assert.Equal(t, audioFrame(200, 1), played[:200])
+	assert.Equal(t, audioFrame(100, 2), played[200:300])
+	assert.Equal(t, audioFrame(3*frameSize-300, silence), played[300:])
+	assert.Equal(t, 2, sim.marks)
+}
+
+func TestSimulatorClear(t *testing.T) {
+	sim, marks := newTestSimulator(t)
+
+	sim.queue = []*playbackEntry{
+		{audio: audioFrame(200, 1)},
+		{mark: "first"},
+		{audio: audioFrame(100, 2)},
+		{mark: "second"},
+	}
+
+	// All the pending marks are sent back right away
+	require.NoError(t, sim.clear())
+	assert.Equal(t, "first", receiveMark(t, marks))
+	assert.Equal(t, "second", receiveMark(t, marks))
+
+	// The pending audio is dropped
+	require.NoError(t, sim.playFrame())
+	assertNoMarks(t, marks)
+
+	assert.Equal(t, audioFrame(frameSize, silence), sim.Played())
+	assert.Equal(t, 1, sim.clears)
+	assert.Equal(t, 2, sim.marks)
+}
+
+// newTestSimulator returns a simulator connected to a fake server, which forwards the received marks to the channel
+func newTestSimulator(t *testing.T) (*Simulator, <-chan string) {
+	marks := make(chan string, 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		for {
+			_, raw, err := conn.ReadMessage()
+
+			if err != nil {
+				return
+			}
+
+			var msg twilio.MarkMessage
+
+			if err := json.Unmarshal(raw, &msg); err != nil || msg.Event != twilio.MarkEvent {
+				continue
+			}
+
+			assert.Equal(t, "sm123", msg.StreamSID)
+
+			marks <- msg.Mark.Name
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { conn.Close() })
+
+	sim := NewSimulator(&Config{StreamSID: "sm123"}, slog.Default())
+	sim.conn = conn
+
+	return sim, marks
+}
+
+func receiveMark(t *testing.T, marks <-chan string) string {
+	select {
+	case mark := <-marks:
+		return mark
+	case <-time.After(2 * time.Second):
+		t.Fatal("mark hasn't been received")
+		return ""
+	}
+}
+
+func assertNoMarks(t *testing.T, marks <-chan string) {
+	select {
+	case mark := <-marks:
+		t.Fatalf("unexpected mark received: %s", mark)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func audioFrame(size int, value byte) []byte {
+	return bytes.Repeat([]byte{value}, size)
+}
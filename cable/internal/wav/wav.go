@@ -0,0 +1,257 @@
+// Package wav implements reading and writing of simple (non-extensible) WAV files.
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/palkan/twilio-ai-cable/internal/g711"
+)
+
+const (
+	FormatPCM   uint16 = 1
+	FormatMulaw uint16 = 7
+)
+
+// Format describes the audio data stored in a WAV file
+type Format struct {
+	AudioFormat   uint16
+	Channels      uint16
+	SampleRate    uint32
+	BitsPerSample uint16
+}
+
+// MulawFormat is the format used by telephony (and Twilio Media Streams): 8kHz mono μ-law
+var MulawFormat = Format{AudioFormat: FormatMulaw, Channels: 1, SampleRate: 8000, BitsPerSample: 8}
+
+// PCMFormat returns 16-bit linear PCM format with the specified number of channels and sample rate
+func PCMFormat(channels uint16, sampleRate uint32) Format {
+	return Format{AudioFormat: FormatPCM, Channels: channels, SampleRate: sampleRate, BitsPerSample: 16}
+}
+
+func (f Format) blockAlign() uint16 {
+	return f.Channels * f.BitsPerSample / 8
+}
+
+func (f Format) String() string {
+	return fmt.Sprintf("format=%d channels=%d rate=%d bits=%d", f.AudioFormat, f.Channels, f.SampleRate, f.BitsPerSample)
+}
+
+// Read reads the whole WAV file and returns its format and raw audio data
+func Read(r io.Reader) (*Format, []byte, error) {
+	var header struct {
+		ChunkID   [4]byte
+		ChunkSize uint32
+		Format    [4]byte
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, nil, err
+	}
+
+	if string(header.ChunkID[:]) != "RIFF" || string(header.Format[:]) != "WAVE" {
+		return nil, nil, errors.New("not a WAV file")
+	}
+
+	var format *Format
+
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, nil, errors.New("no data chunk found")
+			}
+
+			return nil, nil, err
+		}
+
+		body := make([]byte, chunk.Size)
+
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, nil, err
+		}
+
+		// Chunks are word-aligned
+		if chunk.Size%2 == 1 {
+			if _, err := io.CopyN(io.Discard, r, 1); err != nil && !errors.Is(err, io.EOF) {
+				return nil, nil, err
+			}
+		}
+
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			format = &Format{}
+
+			if len(body) < 16 {
+				return nil, nil, errors.New("malformed fmt chunk")
+			}
+
+			format.AudioFormat = binary.LittleEndian.Uint16(body[0:2])
+			format.Channels = binary.LittleEndian.Uint16(body[2:4])
+			format.SampleRate = binary.LittleEndian.Uint32(body[4:8])
+			format.BitsPerSample = binary.LittleEndian.Uint16(body[14:16])
+		case "data":
+			if format == nil {
+				return nil, nil, errors.New("data chunk found before fmt chunk")
+			}
+
+			return format, body, nil
+		}
+	}
+}
+
+// Write writes a complete WAV file with the specified format and raw audio data
+func Write(w io.Writer, f Format, data []byte) error {
+	if err := WriteHeader(w, f, len(data)); err != nil {
+		return err
+	}
+
+	_, err := w.Write(data)
+	return err
+}
+
+// WriteHeader writes the WAV header for the audio data of the specified size,
+// so the data itself could be streamed right after it
+func WriteHeader(w io.Writer, f Format, dataSize int) error {
+	header := struct {
+		ChunkID       [4]byte
+		ChunkSize     uint32
+		Format        [4]byte
+		FmtID         [4]byte
+		FmtSize       uint32
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		DataID        [4]byte
+		DataSize      uint32
+	}{
+		ChunkID:       [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     uint32(36 + dataSize),
+		Format:        [4]byte{'W', 'A', 'V', 'E'},
+		FmtID:         [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		AudioFormat:   f.AudioFormat,
+		Channels:      f.Channels,
+		SampleRate:    f.SampleRate,
+		ByteRate:      f.SampleRate * uint32(f.blockAlign()),
+		BlockAlign:    f.blockAlign(),
+		BitsPerSample: f.BitsPerSample,
+		DataID:        [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      uint32(dataSize),
+	}
+
+	return binary.Write(w, binary.LittleEndian, &header)
+}
+
+// ToMulaw converts the audio data to 8kHz mono μ-law (as expected by Twilio).
+// Only 16-bit PCM and μ-law inputs are supported; multiple channels are mixed down,
+// other sample rates are converted using linear interpolation.
+func ToMulaw(f *Format, data []byte) ([]byte, error) {
+	if f.AudioFormat == FormatMulaw && f.Channels == 1 && f.SampleRate == 8000 {
+		return data, nil
+	}
+
+	var samples []int16
+
+	switch {
+	case f.AudioFormat == FormatMulaw && f.BitsPerSample == 8:
+		samples = make([]int16, len(data))
+
+		for i, b := range data {
+			samples[i] = g711.DecodeUlawFrame(b)
+		}
+	case f.AudioFormat == FormatPCM && f.BitsPerSample == 16:
+		samples = make([]int16, len(data)/2)
+
+		for i := range samples {
+			samples[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported audio format: %s", f)
+	}
+
+	if f.Channels == 0 {
+		return nil, errors.New("malformed format: no channels")
+	}
+
+	// Mix down channels
+	channels := int(f.Channels)
+	mono := make([]int16, len(samples)/channels)
+
+	for i := range mono {
+		var sum int
+
+		for ch := 0; ch < channels; ch++ {
+			sum += int(samples[i*channels+ch])
+		}
+
+		mono[i] = int16(sum / channels)
+	}
+
+	mono = Resample(mono, int(f.SampleRate), 8000)
+
+	ulaw := make([]byte, len(mono))
+
+	for i, sample := range mono {
+		ulaw[i] = g711.EncodeUlawFrame(sample)
+	}
+
+	return ulaw, nil
+}
+
+// FromMulaw converts 8kHz mono μ-law audio to 16-bit linear PCM (little-endian) of the specified sample rate
+func FromMulaw(ulaw []byte, rate int) []byte {
+	if rate == 8000 {
+		return g711.DecodeUlaw(ulaw)
+	}
+
+	samples := make([]int16, len(ulaw))
+
+	for i, b := range ulaw {
+		samples[i] = g711.DecodeUlawFrame(b)
+	}
+
+	samples = Resample(samples, 8000, rate)
+
+	pcm := make([]byte, len(samples)*2)
+
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(sample))
+	}
+
+	return pcm
+}
+
+// Resample converts mono samples from one sample rate to another using linear interpolation
+func Resample(samples []int16, from int, to int) []int16 {
+	if from == to || len(samples) == 0 {
+		return samples
+	}
+
+	n := len(samples) * to / from
+	out := make([]int16, n)
+
+	for i := range out {
+		pos := float64(i) * float64(from) / float64(to)
+		idx := int(pos)
+		frac := pos - float64(idx)
+
+		if idx+1 >= len(samples) {
+			out[i] = samples[len(samples)-1]
+			continue
+		}
+
+		out[i] = int16(float64(samples[idx])*(1-frac) + float64(samples[idx+1])*frac)
+	}
+
+	return out
+}
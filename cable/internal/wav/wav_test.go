@@ -0,0 +1,53 @@
+package wav
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRead(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	data := []byte{0xff, 0x7f, 0x00, 0x80}
+
+	require.NoError(t, Write(buf, MulawFormat, data))
+	assert.Equal(t, 44+len(data), buf.Len())
+
+	format, actual, err := Read(buf)
+
+	require.NoError(t, err)
+	assert.Equal(t, MulawFormat, *format)
+	assert.Equal(t, data, actual)
+}
+
+func TestToMulaw(t *testing.T) {
+	t.Run("mulaw is returned as is", func(t *testing.T) {
+		data := []byte{1, 2, 3}
+
+		actual, err := ToMulaw(&MulawFormat, data)
+
+		require.NoError(t, err)
+		assert.Equal(t, data, actual)
+	})
+
+	t.Run("16kHz stereo PCM", func(t *testing.T) {
+		f := PCMFormat(2, 16000)
+		// 4 frames of silence
+		data := make([]byte, 4*2*2)
+
+		actual, err := ToMulaw(&f, data)
+
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0xff, 0xff}, actual)
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		f := Format{AudioFormat: 3, Channels: 1, SampleRate: 8000, BitsPerSample: 32}
+
+		_, err := ToMulaw(&f, []byte{0, 0, 0, 0})
+
+		require.Error(t, err)
+	})
+}
@@ -3,40 +3,41 @@ package agent
 import (
 	"bytes"
 	"context"
-	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"log/slog"
-	"net/http"
 	"sync"
 
-	"github.com/anycable/anycable-go/logger"
-	"github.com/anycable/anycable-go/utils"
-	"github.com/gorilla/websocket"
 	"github.com/joomcode/errorx"
 )
 
 type TranscriptHandler = func(role string, text string, id string)
 type AudioHandler = func(data string, id string)
 type FunctionHandler = func(name string, args string, id string)
+type SpeechStartedHandler = func(itemID string)
+type UsageHandler = func(usage *Usage, totals UsageTotals)
+type EventHandler = func(ev Event)
 
 // Agent represents a single Twilio Stream consumer connected
-// to OpenAI realtime API
+// to a realtime LLM provider (OpenAI by default)
 type Agent struct {
 	conf *Config
 	buf  *bytes.Buffer
 
-	conn   *websocket.Conn
-	sendCh chan []byte
+	provider Provider
 
 	log *slog.Logger
 
 	transcriptHandler TranscriptHandler
 	audioHandler      AudioHandler
 	functionHandler   FunctionHandler
+	speechHandler     SpeechStartedHandler
+	usageHandler      UsageHandler
+	eventHandler      EventHandler
 
-	cancelFn context.CancelFunc
-	connMu   sync.RWMutex
-	mu       sync.Mutex
+	// Token usage for the whole session
+	usage UsageTotals
+
+	mu sync.Mutex
 }
 
 const (
@@ -48,10 +49,9 @@ const (
 // NewAgent creates a new Agent instance with the given configuration.
 func NewAgent(c *Config, l *slog.Logger) *Agent {
 	return &Agent{
-		conf:   c,
-		buf:    bytes.NewBuffer(nil),
-		sendCh: make(chan []byte, 128),
-		log:    l.With("component", "openai"),
+		conf: c,
+		buf:  bytes.NewBuffer(nil),
+		log:  l.With("component", "agent"),
 	}
 }
 
@@ -67,217 +67,219 @@ func (a *Agent) HandleFunctionCall(handler FunctionHandler) {
 	a.functionHandler = handler
 }
 
-// KickOff starts the OpenAI WebSocket connection.
-func (a *Agent) KickOff(ctx context.Context) error {
-	url := a.conf.URL + "?model=" + a.conf.Model
-	header := http.Header{
-		"Authorization": []string{"Bearer " + a.conf.Key},
-		"OpenAI-Beta":   []string{"realtime=v1"},
-	}
+// HandleSpeechStarted registers a callback to be invoked when the server VAD
+// detects the caller's speech (so we can interrupt the assistant)
+func (a *Agent) HandleSpeechStarted(handler SpeechStartedHandler) {
+	a.speechHandler = handler
+}
+
+// HandleUsage registers a callback to be invoked when a response is completed
+// with the response usage and the session totals
+func (a *Agent) HandleUsage(handler UsageHandler) {
+	a.usageHandler = handler
+}
 
-	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+// OnEvent registers a callback to be invoked for every server event (typed, see ParseEvent),
+// so any event could be observed; it's called from the provider's reader and must not block
+func (a *Agent) OnEvent(handler EventHandler) {
+	a.eventHandler = handler
+}
+
+// KickOff connects to the configured provider (and makes the agent speak first if configured).
+func (a *Agent) KickOff(ctx context.Context) error {
+	provider, err := newProvider(a.conf, a.log)
 
 	if err != nil {
-		return errorx.Decorate(err, "could not dial OpenAI WebSocket")
+		return err
 	}
 
-	ctx, cancel := context.WithCancel(ctx)
-
-	a.connMu.Lock()
-	a.cancelFn = cancel
-	a.conn = conn
-	a.connMu.Unlock()
-
-	a.log.Debug("connected to OpenAI WebSocket")
-
-	// Send session.update message to configure the session
-	sessionConfig := map[string]interface{}{
-		"type": "session.update",
-		"session": map[string]interface{}{
-			"input_audio_format":  "g711_ulaw",
-			"output_audio_format": "g711_ulaw",
-			"input_audio_transcription": map[string]string{
-				"model": "whisper-1",
-			},
-		},
-	}
+	err = provider.Connect(ctx, &Callbacks{
+		Transcript:    a.handleTranscript,
+		Audio:         a.handleAudio,
+		FunctionCall:  a.handleFunctionCall,
+		SpeechStarted: a.handleSpeechStarted,
+		Usage:         a.handleUsage,
+		Event:         a.handleEvent,
+	})
 
-	if a.conf.Prompt != "" {
-		sessionConfig["session"].(map[string]interface{})["instructions"] = a.conf.Prompt
+	if err != nil {
+		return err
 	}
 
-	if a.conf.Tools != nil {
-		sessionConfig["session"].(map[string]interface{})["tools"] = a.conf.Tools
-	}
+	a.mu.Lock()
+	a.provider = provider
+	a.mu.Unlock()
+
+	if a.conf.SpeakFirst {
+		if err := provider.CreateResponse(a.conf.Greeting); err != nil {
+			provider.Close()
 
-	configMessage := utils.ToJSON(sessionConfig)
-	a.sendMsg(configMessage)
+			a.mu.Lock()
+			a.provider = nil
+			a.mu.Unlock()
 
-	go a.readMessages()
-	go a.writeMessages(ctx)
+			return errorx.Decorate(err, "could not greet the caller")
+		}
+	}
 
 	return nil
 }
 
 func (a *Agent) HandleFunctionCallResult(callID string, data string) {
-	item := &Item{Type: "function_call_output", CallID: callID, Output: data}
-
-	msg := struct {
-		Type string `json:"type"`
-		Item *Item  `json:"item"`
-	}{"conversation.item.create", item}
+	if p := a.getProvider(); p != nil {
+		if err := p.SendFunctionCallResult(callID, data); err != nil {
+			a.log.Error("could not send function call result", "err", err)
+		}
+	}
+}
 
-	encoded := utils.ToJSON(msg)
+// SendUserMessage injects a text message from the user (e.g., a caller's action performed not by voice)
+func (a *Agent) SendUserMessage(text string) {
+	if p := a.getProvider(); p != nil {
+		if err := p.SendUserMessage(text); err != nil {
+			a.log.Error("could not send user message", "err", err)
+		}
+	}
+}
 
-	a.log.Warn("sending function call result", "data", string(encoded))
+// UpdateSession changes the agent's prompt, voice or tools mid-call
+func (a *Agent) UpdateSession(update *SessionUpdate) {
+	if p := a.getProvider(); p != nil {
+		if err := p.UpdateSession(update); err != nil {
+			a.log.Error("could not update session", "err", err)
+		}
+	}
+}
 
-	a.sendMsg(encoded)
-	// Send `response.create` message right away to trigger model inference
-	a.sendMsg([]byte(`{"type":"response.create"}`))
+// CreateResponse makes the agent respond following the instructions
+// without waiting for the caller's input
+func (a *Agent) CreateResponse(instructions string) {
+	if p := a.getProvider(); p != nil {
+		if err := p.CreateResponse(instructions); err != nil {
+			a.log.Error("could not create response", "err", err)
+		}
+	}
 }
 
-func (a *Agent) EnqueueAudio(audio []byte) error {
+// CommitInput flushes the buffered caller's audio, commits it as a user message and requests a response
+// (for manual turn detection)
+func (a *Agent) CommitInput() {
 	a.mu.Lock()
-	defer a.mu.Unlock()
+	p := a.provider
 
-	a.buf.Write(audio)
+	if p == nil {
+		a.mu.Unlock()
+		return
+	}
 
-	if a.buf.Len() > bytesPerFlush {
-		if err := a.sendAudio(a.buf.Bytes()); err != nil {
-			return errorx.Decorate(err, "could not send audio")
-		}
+	var err error
 
+	if a.buf.Len() > 0 {
+		err = p.SendAudio(a.buf.Bytes())
 		a.buf.Reset()
 	}
 
-	return nil
-}
+	a.mu.Unlock()
 
-func (a *Agent) Close() {
-	a.connMu.RLock()
-	defer a.connMu.RUnlock()
+	if err == nil {
+		err = p.CommitInput()
+	}
 
-	if a.cancelFn != nil {
-		a.cancelFn()
+	if err != nil {
+		a.log.Error("could not commit input", "err", err)
 	}
+}
 
-	if a.conn != nil {
-		a.conn.Close()
+// CancelResponse cancels the in-flight response (if any)
+func (a *Agent) CancelResponse() {
+	if p := a.getProvider(); p != nil {
+		if err := p.CancelResponse(); err != nil {
+			a.log.Error("could not cancel response", "err", err)
+		}
 	}
 }
 
-func (a *Agent) readMessages() {
-	for {
-		_, msg, err := a.conn.ReadMessage()
-		if err != nil {
-			a.log.Error("could not read message from OpenAI WebSocket", "err", err)
-			return
+// TruncateItem notifies the server that only the first audioEndMs of the assistant's item
+// have been heard by the user, so the conversation history matches the reality
+func (a *Agent) TruncateItem(itemID string, audioEndMs int) {
+	if p := a.getProvider(); p != nil {
+		if err := p.TruncateItem(itemID, audioEndMs); err != nil {
+			a.log.Error("could not truncate item", "err", err)
 		}
+	}
+}
+
+func (a *Agent) EnqueueAudio(audio []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-		a.log.Debug("received message from OpenAI WebSocket", "msg", logger.CompactValue(string(msg)))
+	if a.provider == nil {
+		return nil
+	}
+
+	a.buf.Write(audio)
+
+	if a.buf.Len() > bytesPerFlush {
+		err := a.provider.SendAudio(a.buf.Bytes())
+		a.buf.Reset()
 
-		var typedMessage struct {
-			Type string `json:"type"`
+		// The agent has been stopped, it's reported via Done()
+		if errors.Is(err, ErrClosed) {
+			return nil
 		}
 
-		_ = json.Unmarshal(msg, &typedMessage)
-
-		switch typedMessage.Type {
-		case "session.created":
-		case "session.updated":
-		case "input_audio_buffer.speech_started":
-		case "input_audio_buffer.speech_stopped":
-		case "input_audio_buffer.committed":
-		case "conversation.item.input_audio_transcription.completed":
-			var event *InputAudioTranscriptionCompletedEvent
-			_ = json.Unmarshal(msg, &event)
-
-			a.handleTranscript(event)
-		case "response.created":
-		case "rate_limits.updated":
-		case "response.output_item.added":
-		case "conversation.item.created":
-		case "response.content_part.added":
-		case "response.audio.delta":
-			var event *AudioDeltaEvent
-			_ = json.Unmarshal(msg, &event)
-
-			a.handleAudio(event)
-		case "response.audio_transcript.delta":
-			var event *AudioTranscriptDeltaEvent
-			_ = json.Unmarshal(msg, &event)
-
-			a.handleTranscript(event)
-		case "response.audio.done":
-		case "response.audio_transcript.done":
-			var event *AudioTranscriptDoneEvent
-			_ = json.Unmarshal(msg, &event)
-
-			a.handleTranscript(event)
-		case "response.function_call_arguments.delta":
-		case "response.function_call_arguments.done":
-		case "response.content_part.done":
-		case "response.output_item.done":
-			var event *OutputItemDoneEvent
-			_ = json.Unmarshal(msg, &event)
-
-			if event.Item.Type == "function_call" {
-				a.handleFunctionCall(event.Item)
-			}
-		case "response.done":
-			var event *ResponseEvent
-			_ = json.Unmarshal(msg, &event)
-
-			// Log errors
-			if event.Response.Status == "failed" {
-				a.log.Error("request failed", "error", event.Response.StatusDetails.Error)
-			}
-		case "error":
-			a.log.Error("server error", "err", string(msg))
-		default:
-			a.log.Warn("unhandled message type", "type", typedMessage.Type)
+		if err != nil {
+			return errorx.Decorate(err, "could not send audio")
 		}
 	}
+
+	return nil
 }
 
-func (a *Agent) writeMessages(ctx context.Context) {
-	for {
-		select {
-		case msg := <-a.sendCh:
-			if err := a.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-				a.log.Error("could not write message to OpenAI WebSocket", "err", err)
-				return
-			}
-		case <-ctx.Done():
-			_ = a.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-			return
-		}
-	}
+// Usage returns the token usage for the session so far
+func (a *Agent) Usage() UsageTotals {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.usage
 }
 
-func (a *Agent) sendMsg(msg []byte) {
-	a.sendCh <- msg
+func (a *Agent) Close() {
+	if p := a.getProvider(); p != nil {
+		p.Close()
+	}
 }
 
-func (a *Agent) sendAudio(audio []byte) error {
-	encoded := base64.StdEncoding.EncodeToString(audio)
+// Done returns a channel which is closed when the agent stops (nil if the agent hasn't been started)
+func (a *Agent) Done() <-chan struct{} {
+	if p := a.getProvider(); p != nil {
+		return p.Done()
+	}
+
+	return nil
+}
 
-	msg := []byte(`{"type":"input_audio_buffer.append","audio": "` + encoded + `"}`)
-	a.sendMsg(msg)
+// Err returns the reason the agent has failed (e.g., the connection is lost or the send queue is full)
+func (a *Agent) Err() error {
+	if p := a.getProvider(); p != nil {
+		return p.Err()
+	}
 
 	return nil
 }
 
-func (a *Agent) handleTranscript(ev TranscriptEvent) {
-	text := ev.GetTranscript()
+func (a *Agent) getProvider() Provider {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.provider
+}
 
+func (a *Agent) handleTranscript(role string, text string, id string) {
 	if text == "" {
 		return
 	}
 
-	role := ev.GetRole()
-	id := ev.GetItemId()
-
 	a.log.Info("transcript", "text", text, "role", role, "id", id)
 
 	if a.transcriptHandler != nil {
@@ -285,16 +287,41 @@ func (a *Agent) handleTranscript(ev TranscriptEvent) {
 	}
 }
 
-func (a *Agent) handleAudio(ev *AudioDeltaEvent) {
+func (a *Agent) handleAudio(data string, id string) {
 	if a.audioHandler != nil {
-		a.audioHandler(ev.Delta, ev.ItemId)
+		a.audioHandler(data, id)
 	}
 }
 
-func (a *Agent) handleFunctionCall(item *Item) {
-	a.log.Debug("agent is trying to call a function", "name", item.Name, "args", item.Arguments, "id", item.CallID)
+func (a *Agent) handleFunctionCall(name string, args string, id string) {
+	a.log.Debug("agent is trying to call a function", "name", name, "args", args, "id", id)
 
 	if a.functionHandler != nil {
-		a.functionHandler(item.Name, item.Arguments, item.CallID)
+		a.functionHandler(name, args, id)
+	}
+}
+
+func (a *Agent) handleSpeechStarted(id string) {
+	if a.speechHandler != nil {
+		a.speechHandler(id)
+	}
+}
+
+func (a *Agent) handleEvent(ev Event) {
+	if a.eventHandler != nil {
+		a.eventHandler(ev)
+	}
+}
+
+func (a *Agent) handleUsage(usage *Usage) {
+	a.mu.Lock()
+	a.usage.Add(usage)
+	totals := a.usage
+	a.mu.Unlock()
+
+	a.log.Debug("response usage", "total_tokens", usage.TotalTokens, "session_total_tokens", totals.TotalTokens)
+
+	if a.usageHandler != nil {
+		a.usageHandler(usage, totals)
 	}
 }
@@ -0,0 +1,812 @@
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palkan/twilio-ai-cable/internal/fake_openai"
+)
+
+const timeout = 2 * time.Second
+
+func TestAgentKickOff(t *testing.T) {
+	srv := newTestServer(t)
+
+	conf := newTestConfig(srv)
+	conf.Prompt = "Be nice"
+	conf.Tools = json.RawMessage(`[{"type":"function","name":"get_tasks"}]`)
+
+	agent := NewAgent(conf, slog.Default())
+
+	conn := kickOff(t, srv, agent)
+
+	assert.Equal(t, "Bearer sk-test", conn.Header.Get("Authorization"))
+	assert.Equal(t, []string{conf.Model}, conn.Query["model"])
+
+	msg, err := conn.WaitFor("session.update", timeout)
+	require.NoError(t, err)
+
+	var update struct {
+		Session struct {
+			Instructions string            `json:"instructions"`
+			Tools        []json.RawMessage `json:"tools"`
+		} `json:"session"`
+	}
+
+	require.NoError(t, json.Unmarshal(msg, &update))
+
+	assert.Equal(t, "Be nice", update.Session.Instructions)
+	assert.Len(t, update.Session.Tools, 1)
+}
+
+func TestAgentKickOffSpeakFirst(t *testing.T) {
+	srv := newTestServer(t)
+
+	conf := newTestConfig(srv)
+	conf.SpeakFirst = true
+	conf.Greeting = "Greet the caller by name: John"
+
+	agent := NewAgent(conf, slog.Default())
+
+	conn := kickOff(t, srv, agent)
+
+	_, err := conn.WaitFor("session.update", timeout)
+	require.NoError(t, err)
+
+	msg, err := conn.WaitFor("response.create", timeout)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(msg), `"instructions":"Greet the caller by name: John"`)
+}
+
+// failingGreetingProvider is an OpenAI provider which fails to create responses
+type failingGreetingProvider struct {
+	*OpenAIProvider
+}
+
+func (p *failingGreetingProvider) CreateResponse(instructions string) error {
+	return errors.New("send queue is full")
+}
+
+func TestAgentKickOffGreetingFailed(t *testing.T) {
+	srv := newTestServer(t)
+
+	var provider *failingGreetingProvider
+
+	RegisterProvider("failing_greeting", func(c *Config, l *slog.Logger) Provider {
+		provider = &failingGreetingProvider{NewOpenAIProvider(c, l)}
+		return provider
+	})
+
+	conf := newTestConfig(srv)
+	conf.Provider = "failing_greeting"
+	conf.SpeakFirst = true
+
+	agent := NewAgent(conf, slog.Default())
+
+	err := agent.KickOff(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "could not greet the caller")
+
+	// The connection is closed and the agent is not considered started
+	select {
+	case <-provider.Done():
+	case <-time.After(timeout):
+		t.Fatal("provider hasn't been closed")
+	}
+
+	assert.NoError(t, provider.Err())
+	assert.Nil(t, agent.Done())
+}
+
+func TestAgentTurnDetection(t *testing.T) {
+	srv := newTestServer(t)
+
+	t.Run("default", func(t *testing.T) {
+		conf := newTestConfig(srv)
+
+		agent := NewAgent(conf, slog.Default())
+
+		conn := kickOff(t, srv, agent)
+
+		msg, err := conn.WaitFor("session.update", timeout)
+		require.NoError(t, err)
+
+		assert.NotContains(t, string(msg), "turn_detection")
+	})
+
+	t.Run("server VAD", func(t *testing.T) {
+		conf := newTestConfig(srv)
+		conf.TurnDetection = &TurnDetection{Threshold: 0.8, PrefixPaddingMs: 200, SilenceDurationMs: 700}
+
+		agent := NewAgent(conf, slog.Default())
+
+		conn := kickOff(t, srv, agent)
+
+		msg, err := conn.WaitFor("session.update", timeout)
+		require.NoError(t, err)
+
+		var update struct {
+			Session struct {
+				TurnDetection map[string]interface{} `json:"turn_detection"`
+			} `json:"session"`
+		}
+
+		require.NoError(t, json.Unmarshal(msg, &update))
+
+		assert.Equal(t, map[string]interface{}{
+			"type":                "server_vad",
+			"threshold":           0.8,
+			"prefix_padding_ms":   float64(200),
+			"silence_duration_ms": float64(700),
+		}, update.Session.TurnDetection)
+	})
+
+	t.Run("manual", func(t *testing.T) {
+		conf := newTestConfig(srv)
+		conf.TurnDetection = &TurnDetection{Mode: TurnDetectionManual}
+
+		agent := NewAgent(conf, slog.Default())
+
+		conn := kickOff(t, srv, agent)
+
+		msg, err := conn.WaitFor("session.update", timeout)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(msg), `"turn_detection":null`)
+
+		// Buffered audio is flushed before the commit
+		require.NoError(t, agent.EnqueueAudio(make([]byte, 160)))
+
+		agent.CommitInput()
+
+		_, err = conn.WaitFor("response.create", timeout)
+		require.NoError(t, err)
+
+		var types []string
+
+		for _, raw := range conn.Received() {
+			var msg struct {
+				Type string `json:"type"`
+			}
+
+			require.NoError(t, json.Unmarshal(raw, &msg))
+
+			types = append(types, msg.Type)
+		}
+
+		assert.Equal(t, []string{"session.update", "input_audio_buffer.append", "input_audio_buffer.commit", "response.create"}, types)
+	})
+}
+
+func TestAgentKickOffUnknownProvider(t *testing.T) {
+	conf := NewConfig("sk-test")
+	conf.Provider = "unknown"
+
+	agent := NewAgent(conf, slog.Default())
+
+	err := agent.KickOff(context.Background())
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown provider")
+}
+
+func TestAgentEvents(t *testing.T) {
+	srv := newTestServer(t)
+
+	conf := newTestConfig(srv)
+
+	agent := NewAgent(conf, slog.Default())
+
+	transcripts := make(chan []string, 10)
+	audio := make(chan []string, 10)
+	calls := make(chan []string, 10)
+	speech := make(chan string, 10)
+	usages := make(chan UsageTotals, 10)
+
+	agent.HandleTranscript(func(role string, text string, id string) {
+		transcripts <- []string{role, text, id}
+	})
+	agent.HandleAudio(func(data string, id string) {
+		audio <- []string{data, id}
+	})
+	agent.HandleFunctionCall(func(name string, args string, id string) {
+		calls <- []string{name, args, id}
+	})
+	agent.HandleSpeechStarted(func(id string) {
+		speech <- id
+	})
+	agent.HandleUsage(func(usage *Usage, totals UsageTotals) {
+		usages <- totals
+	})
+
+	conn := kickOff(t, srv, agent)
+
+	t.Run("audio delta", func(t *testing.T) {
+		require.NoError(t, conn.SendAudioDelta("resp_1", "item_1", []byte{1, 2, 3}))
+
+		received := receive(t, audio)
+		assert.Equal(t, []string{base64.StdEncoding.EncodeToString([]byte{1, 2, 3}), "item_1"}, received)
+	})
+
+	t.Run("transcripts", func(t *testing.T) {
+		require.NoError(t, conn.SendInputTranscript("item_0", "Hello"))
+		require.NoError(t, conn.SendAudioTranscript("resp_1", "item_1", "Hi there"))
+
+		assert.Equal(t, []string{"user", "Hello", "item_0"}, receive(t, transcripts))
+		assert.Equal(t, []string{"assistant", "Hi there", "item_1"}, receive(t, transcripts))
+	})
+
+	t.Run("function call", func(t *testing.T) {
+		require.NoError(t, conn.SendFunctionCall("resp_2", "item_2", "call_1", "get_tasks", `{"period":"today"}`))
+
+		assert.Equal(t, []string{"get_tasks", `{"period":"today"}`, "call_1"}, receive(t, calls))
+
+		agent.HandleFunctionCallResult("call_1", `{"todos":[]}`)
+
+		msg, err := conn.WaitFor("conversation.item.create", timeout)
+		require.NoError(t, err)
+
+		var create struct {
+			Item Item `json:"item"`
+		}
+
+		require.NoError(t, json.Unmarshal(msg, &create))
+
+		assert.Equal(t, "function_call_output", create.Item.Type)
+		assert.Equal(t, "call_1", create.Item.CallID)
+		assert.Equal(t, `{"todos":[]}`, create.Item.Output)
+
+		_, err = conn.WaitFor("response.create", timeout)
+		require.NoError(t, err)
+	})
+
+	t.Run("speech started cancels the active response", func(t *testing.T) {
+		require.NoError(t, conn.SendResponseCreated("resp_3"))
+		require.NoError(t, conn.SendSpeechStarted("item_4"))
+
+		assert.Equal(t, "item_4", receive(t, speech))
+
+		agent.CancelResponse()
+
+		_, err := conn.WaitFor("response.cancel", timeout)
+		require.NoError(t, err)
+	})
+
+	t.Run("update session", func(t *testing.T) {
+		agent.UpdateSession(&SessionUpdate{Prompt: "You're a billing assistant", Tools: json.RawMessage(`[{"type":"function","name":"pay"}]`)})
+
+		assert.Eventually(t, func() bool {
+			for _, msg := range conn.Received() {
+				str := string(msg)
+
+				if strings.Contains(str, `"type":"session.update"`) && strings.Contains(str, `"instructions":"You're a billing assistant"`) {
+					return strings.Contains(str, `"name":"pay"`) && strings.Contains(str, `"voice":"alloy"`)
+				}
+			}
+
+			return false
+		}, timeout, 10*time.Millisecond)
+	})
+
+	t.Run("send user message", func(t *testing.T) {
+		agent.SendUserMessage("The caller pressed 1")
+
+		assert.Eventually(t, func() bool {
+			for _, msg := range conn.Received() {
+				str := string(msg)
+
+				if strings.Contains(str, `"type":"conversation.item.create"`) && strings.Contains(str, `"role":"user"`) {
+					return strings.Contains(str, `{"type":"input_text","text":"The caller pressed 1"}`)
+				}
+			}
+
+			return false
+		}, timeout, 10*time.Millisecond)
+	})
+
+	t.Run("create response with instructions", func(t *testing.T) {
+		agent.CreateResponse("Say goodbye")
+
+		// response.create has been already sent after the function call result
+		assert.Eventually(t, func() bool {
+			for _, msg := range conn.Received() {
+				if string(msg) == `{"type":"response.create","response":{"instructions":"Say goodbye"}}` {
+					return true
+				}
+			}
+
+			return false
+		}, timeout, 10*time.Millisecond)
+	})
+
+	t.Run("usage is accumulated", func(t *testing.T) {
+		usage := map[string]interface{}{
+			"total_tokens":  30,
+			"input_tokens":  20,
+			"output_tokens": 10,
+			"input_token_details": map[string]int{
+				"cached_tokens": 5,
+				"text_tokens":   12,
+				"audio_tokens":  8,
+			},
+			"output_token_details": map[string]int{
+				"text_tokens":  4,
+				"audio_tokens": 6,
+			},
+		}
+
+		require.NoError(t, conn.SendResponseDone("resp_3", "completed", usage))
+		require.NoError(t, conn.SendResponseDone("resp_4", "cancelled", usage))
+		// Responses with no usage are ignored
+		require.NoError(t, conn.SendResponseDone("resp_5", "completed", nil))
+
+		receive(t, usages)
+		totals := receive(t, usages)
+
+		expected := UsageTotals{
+			Responses:         2,
+			TotalTokens:       60,
+			InputTokens:       40,
+			OutputTokens:      20,
+			CachedTokens:      10,
+			InputTextTokens:   24,
+			InputAudioTokens:  16,
+			OutputTextTokens:  8,
+			OutputAudioTokens: 12,
+		}
+
+		assert.Equal(t, expected, totals)
+		assert.Eventually(t, func() bool { return agent.Usage() == expected }, timeout, 10*time.Millisecond)
+	})
+
+	t.Run("errors are not fatal", func(t *testing.T) {
+		require.NoError(t, conn.SendError("invalid_value", "Something went wrong"))
+		require.NoError(t, conn.SendAudioDelta("resp_4", "item_5", []byte{4}))
+
+		received := receive(t, audio)
+		assert.Equal(t, "item_5", received[1])
+	})
+
+	t.Run("enqueued audio is flushed in chunks", func(t *testing.T) {
+		require.NoError(t, agent.EnqueueAudio(make([]byte, bytesPerFlush)))
+		require.NoError(t, agent.EnqueueAudio(make([]byte, 160)))
+
+		msg, err := conn.WaitFor("input_audio_buffer.append", timeout)
+		require.NoError(t, err)
+
+		var appendMsg struct {
+			Audio string `json:"audio"`
+		}
+
+		require.NoError(t, json.Unmarshal(msg, &appendMsg))
+
+		decoded, err := base64.StdEncoding.DecodeString(appendMsg.Audio)
+		require.NoError(t, err)
+		assert.Len(t, decoded, bytesPerFlush+160)
+	})
+}
+
+func TestAgentOnEvent(t *testing.T) {
+	srv := newTestServer(t)
+
+	conf := newTestConfig(srv)
+
+	agent := NewAgent(conf, slog.Default())
+
+	events := make(chan Event, 100)
+	transcripts := make(chan string, 10)
+
+	agent.OnEvent(func(ev Event) {
+		events <- ev
+	})
+	agent.HandleTranscript(func(role string, text string, id string) {
+		transcripts <- text
+	})
+
+	conn := kickOff(t, srv, agent)
+
+	next := func(eventType string) Event {
+		t.Helper()
+
+		for {
+			ev := receive(t, events)
+
+			if ev == nil || ev.GetType() == eventType {
+				return ev
+			}
+		}
+	}
+
+	require.NoError(t, conn.Send(map[string]interface{}{
+		"event_id":    "ev_1",
+		"type":        "rate_limits.updated",
+		"rate_limits": []map[string]interface{}{{"name": "tokens", "limit": 100, "remaining": 42, "reset_seconds": 1.5}},
+	}))
+
+	limits, ok := next("rate_limits.updated").(*RateLimitsUpdatedEvent)
+	require.True(t, ok)
+	assert.Equal(t, 42, limits.RateLimits[0].Remaining)
+
+	require.NoError(t, conn.Send(map[string]interface{}{"event_id": "ev_2", "type": "response.brand_new"}))
+
+	unknown, ok := next("response.brand_new").(*UnknownEvent)
+	require.True(t, ok)
+	assert.Equal(t, "ev_2", unknown.GetEventId())
+
+	// Events are still handled by the agent
+	require.NoError(t, conn.SendInputTranscript("item_1", "Hello"))
+
+	_, ok = next("conversation.item.input_audio_transcription.completed").(*InputAudioTranscriptionCompletedEvent)
+	require.True(t, ok)
+	assert.Equal(t, "Hello", receive(t, transcripts))
+}
+
+// newTestServer returns a fake OpenAI server, which is closed after the agents when the test finishes
+func newTestServer(t *testing.T) *fake_openai.Server {
+	srv := fake_openai.NewServer()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// newTestConfig returns the agent's configuration pointing to the fake server
+func newTestConfig(srv *fake_openai.Server) *Config {
+	conf := NewConfig("sk-test")
+	conf.URL = srv.URL()
+
+	return conf
+}
+
+// kickOff starts the agent (it's closed when the test finishes) and returns its connection to the fake server
+func kickOff(t *testing.T, srv *fake_openai.Server, agent *Agent) *fake_openai.Conn {
+	t.Helper()
+
+	require.NoError(t, agent.KickOff(context.Background()))
+	t.Cleanup(agent.Close)
+
+	conn, err := srv.NextConn(timeout)
+	require.NoError(t, err)
+
+	return conn
+}
+
+func receive[T any](t *testing.T, ch chan T) T {
+	t.Helper()
+
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(timeout):
+		t.Fatal("timed out waiting for an event")
+	}
+
+	var zero T
+	return zero
+}
+
+func TestAgentReconnect(t *testing.T) {
+	srv := newTestServer(t)
+
+	conf := newTestConfig(srv)
+	conf.Prompt = "Be nice"
+
+	agent := NewAgent(conf, slog.Default())
+
+	transcripts := make(chan []string, 10)
+	calls := make(chan []string, 10)
+
+	agent.HandleTranscript(func(role string, text string, id string) {
+		transcripts <- []string{role, text, id}
+	})
+	agent.HandleFunctionCall(func(name string, args string, id string) {
+		calls <- []string{name, args, id}
+	})
+
+	conn := kickOff(t, srv, agent)
+
+	require.NoError(t, conn.SendInputTranscript("item_1", "What's up?"))
+	require.NoError(t, conn.SendAudioTranscript("resp_1", "item_2", "Let me check"))
+	require.NoError(t, conn.SendFunctionCall("resp_1", "item_3", "call_1", "get_tasks", "{}"))
+
+	receive(t, transcripts)
+	receive(t, transcripts)
+	receive(t, calls)
+
+	agent.HandleFunctionCallResult("call_1", `{"todos":[]}`)
+
+	_, err := conn.WaitFor("conversation.item.create", timeout)
+	require.NoError(t, err)
+
+	// Drop the connection
+	conn.Close()
+
+	newConn, err := srv.NextConn(timeout)
+	require.NoError(t, err)
+
+	msg, err := newConn.WaitFor("session.update", timeout)
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), `"instructions":"Be nice"`)
+
+	require.NoError(t, agent.EnqueueAudio(make([]byte, bytesPerFlush+1)))
+
+	_, err = newConn.WaitFor("input_audio_buffer.append", timeout)
+	require.NoError(t, err)
+
+	var items []Item
+
+	for _, raw := range newConn.Received() {
+		var msg struct {
+			Type string `json:"type"`
+			Item Item   `json:"item"`
+		}
+
+		require.NoError(t, json.Unmarshal(raw, &msg))
+
+		if msg.Type == "input_audio_buffer.append" {
+			break
+		}
+
+		if msg.Type == "conversation.item.create" {
+			items = append(items, msg.Item)
+		}
+	}
+
+	// The conversation is replayed before the new audio
+	require.Len(t, items, 4)
+
+	assert.Equal(t, "user", items[0].Role)
+	assert.Equal(t, "What's up?", items[0].Content[0].Text)
+	assert.Equal(t, "input_text", items[0].Content[0].Type)
+
+	assert.Equal(t, "assistant", items[1].Role)
+	assert.Equal(t, "Let me check", items[1].Content[0].Text)
+	assert.Equal(t, "text", items[1].Content[0].Type)
+
+	assert.Equal(t, "function_call", items[2].Type)
+	assert.Equal(t, "call_1", items[2].CallID)
+	assert.Equal(t, "get_tasks", items[2].Name)
+
+	assert.Equal(t, "function_call_output", items[3].Type)
+	assert.Equal(t, "call_1", items[3].CallID)
+	assert.Equal(t, `{"todos":[]}`, items[3].Output)
+}
+
+func TestAgentReconnectQueuedItems(t *testing.T) {
+	srv := newTestServer(t)
+
+	conf := newTestConfig(srv)
+
+	agent := NewAgent(conf, slog.Default())
+
+	conn := kickOff(t, srv, agent)
+
+	_, err := conn.WaitFor("session.update", timeout)
+	require.NoError(t, err)
+
+	// Items are enqueued while the connection is being lost
+	conn.Close()
+
+	agent.SendUserMessage("Are you there?")
+	agent.HandleFunctionCallResult("call_1", `{"todos":[]}`)
+
+	newConn, err := srv.NextConn(timeout)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return len(receivedTypes(t, newConn, "response.create")) == 2
+	}, timeout, 10*time.Millisecond)
+
+	items := receivedItems(t, newConn)
+
+	// Each item is created once (either replayed or sent from the queue)
+	require.Len(t, items, 2)
+	assert.Equal(t, "Are you there?", items[0].Content[0].Text)
+	assert.Equal(t, "function_call_output", items[1].Type)
+}
+
+func TestAgentReconnectTruncatedItems(t *testing.T) {
+	srv := newTestServer(t)
+
+	conf := newTestConfig(srv)
+
+	agent := NewAgent(conf, slog.Default())
+
+	transcripts := make(chan []string, 10)
+
+	agent.HandleTranscript(func(role string, text string, id string) {
+		transcripts <- []string{role, text, id}
+	})
+
+	conn := kickOff(t, srv, agent)
+
+	// 1s of audio
+	require.NoError(t, conn.SendAudioDelta("resp_1", "item_1", make([]byte, 8000)))
+	require.NoError(t, conn.SendAudioTranscript("resp_1", "item_1", "one two three four five six"))
+	receive(t, transcripts)
+
+	// Another item has been interrupted before its transcript is received
+	require.NoError(t, conn.SendAudioDelta("resp_2", "item_2", make([]byte, 8000)))
+	agent.TruncateItem("item_2", 0)
+	require.NoError(t, conn.SendAudioTranscript("resp_2", "item_2", "never heard"))
+	receive(t, transcripts)
+
+	// The caller has heard only the half of the first item
+	agent.TruncateItem("item_1", 500)
+
+	_, err := conn.WaitFor("conversation.item.truncate", timeout)
+	require.NoError(t, err)
+
+	conn.Close()
+
+	newConn, err := srv.NextConn(timeout)
+	require.NoError(t, err)
+
+	require.NoError(t, agent.EnqueueAudio(make([]byte, bytesPerFlush+1)))
+
+	_, err = newConn.WaitFor("input_audio_buffer.append", timeout)
+	require.NoError(t, err)
+
+	items := receivedItems(t, newConn)
+
+	require.Len(t, items, 1)
+	assert.Equal(t, "one two three", items[0].Content[0].Text)
+}
+
+func receivedItems(t *testing.T, conn *fake_openai.Conn) []Item {
+	t.Helper()
+
+	var items []Item
+
+	for _, raw := range conn.Received() {
+		var msg struct {
+			Type string `json:"type"`
+			Item Item   `json:"item"`
+		}
+
+		require.NoError(t, json.Unmarshal(raw, &msg))
+
+		if msg.Type == "conversation.item.create" {
+			items = append(items, msg.Item)
+		}
+	}
+
+	return items
+}
+
+func receivedTypes(t *testing.T, conn *fake_openai.Conn, eventType string) []json.RawMessage {
+	t.Helper()
+
+	var res []json.RawMessage
+
+	for _, raw := range conn.Received() {
+		var msg struct {
+			Type string `json:"type"`
+		}
+
+		require.NoError(t, json.Unmarshal(raw, &msg))
+
+		if msg.Type == eventType {
+			res = append(res, raw)
+		}
+	}
+
+	return res
+}
+
+func TestAgentReconnectDisabled(t *testing.T) {
+	srv := newTestServer(t)
+
+	conf := newTestConfig(srv)
+	conf.ReconnectAttempts = 0
+
+	agent := NewAgent(conf, slog.Default())
+
+	conn := kickOff(t, srv, agent)
+
+	conn.Close()
+
+	_, err := srv.NextConn(500 * time.Millisecond)
+	require.Error(t, err)
+}
+
+func TestAgentDone(t *testing.T) {
+	srv := newTestServer(t)
+
+	t.Run("closed explicitly", func(t *testing.T) {
+		conf := newTestConfig(srv)
+
+		agent := NewAgent(conf, slog.Default())
+
+		require.NoError(t, agent.KickOff(context.Background()))
+
+		_, err := srv.NextConn(timeout)
+		require.NoError(t, err)
+
+		agent.Close()
+
+		receive(t, toChan(agent.Done()))
+		assert.NoError(t, agent.Err())
+	})
+
+	t.Run("connection is lost", func(t *testing.T) {
+		conf := newTestConfig(srv)
+		conf.ReconnectAttempts = 0
+
+		agent := NewAgent(conf, slog.Default())
+
+		conn := kickOff(t, srv, agent)
+
+		conn.Close()
+
+		receive(t, toChan(agent.Done()))
+		assert.Error(t, agent.Err())
+
+		// Sending audio to the stopped agent doesn't block
+		for i := 0; i < 200; i++ {
+			require.NoError(t, agent.EnqueueAudio(make([]byte, bytesPerFlush+1)))
+		}
+	})
+
+	t.Run("send queue overflow with fail policy", func(t *testing.T) {
+		conf := newTestConfig(srv)
+		conf.ReconnectAttempts = 10
+		conf.SendQueueSize = 2
+		conf.OverflowPolicy = OverflowFail
+
+		agent := NewAgent(conf, slog.Default())
+
+		conn := kickOff(t, srv, agent)
+
+		// Messages are queued while reconnecting
+		srv.Close()
+		conn.Close()
+
+		require.Eventually(t, func() bool {
+			agent.CreateResponse("")
+			agent.CreateResponse("")
+			agent.CreateResponse("")
+
+			select {
+			case <-agent.Done():
+				return true
+			default:
+				return false
+			}
+		}, timeout, 10*time.Millisecond)
+
+		assert.ErrorIs(t, agent.Err(), ErrSendQueueFull)
+	})
+}
+
+// toChan converts the done channel to a channel of values to use with receive
+func toChan(done <-chan struct{}) chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	go func() {
+		<-done
+		ch <- struct{}{}
+	}()
+
+	return ch
+}
+
+func TestTurnDetectionValidate(t *testing.T) {
+	assert.NoError(t, (*TurnDetection)(nil).Validate())
+	assert.NoError(t, (&TurnDetection{}).Validate())
+	assert.NoError(t, (&TurnDetection{Mode: TurnDetectionServerVAD, Threshold: 0.8}).Validate())
+	assert.NoError(t, (&TurnDetection{Mode: TurnDetectionManual}).Validate())
+
+	assert.Error(t, (&TurnDetection{Mode: "semantic"}).Validate())
+	assert.Error(t, (&TurnDetection{Threshold: 1.5}).Validate())
+}
@@ -1,20 +1,92 @@
 package agent
 
+import "fmt"
+
 type Config struct {
-	URL    string
-	Key    string
-	Model  string
-	Voice  string
-	Prompt string
+	// Provider name (see RegisterProvider), OpenAI is used by default
+	Provider string
+	URL      string
+	Key      string
+	Model    string
+	Voice    string
+	Prompt   string
 	// we just pass them as is to the AI
 	Tools interface{}
+	// Whether the agent should greet the caller right after the session is configured
+	SpeakFirst bool
+	// Instructions for the greeting response (optional)
+	Greeting string
+	// Turn detection settings (the provider's defaults are used if nil)
+	TurnDetection *TurnDetection
+	// How many times to try to reconnect when the connection is lost (0 disables reconnection)
+	ReconnectAttempts int
+	// Max number of outbound messages waiting to be sent
+	SendQueueSize int
+	// What to do when the send queue is full (OverflowDropAudio or OverflowFail)
+	OverflowPolicy string
+}
+
+// Turn detection modes
+const (
+	// The server detects the end of the caller's turn by silence
+	TurnDetectionServerVAD = "server_vad"
+	// Turn detection is disabled, the caller's input must be committed explicitly (see Agent.CommitInput)
+	TurnDetectionManual = "manual"
+)
+
+// TurnDetection configures how the end of the caller's turn is detected (zero values mean the provider's defaults)
+type TurnDetection struct {
+	// TurnDetectionServerVAD (default) or TurnDetectionManual
+	Mode string `json:"mode,omitempty" yaml:"mode"`
+	// VAD activation threshold (0.0-1.0); higher values work better in noisy environments
+	Threshold float64 `json:"threshold,omitempty" yaml:"threshold"`
+	// Audio to include before the detected speech
+	PrefixPaddingMs int `json:"prefix_padding_ms,omitempty" yaml:"prefix_padding_ms"`
+	// Silence duration to detect the end of speech
+	SilenceDurationMs int `json:"silence_duration_ms,omitempty" yaml:"silence_duration_ms"`
+}
+
+// Manual returns true if turn detection is disabled
+func (td *TurnDetection) Manual() bool {
+	return td != nil && td.Mode == TurnDetectionManual
+}
+
+// Validate returns an error if the settings are not supported
+func (td *TurnDetection) Validate() error {
+	if td == nil {
+		return nil
+	}
+
+	switch td.Mode {
+	case "", TurnDetectionServerVAD, TurnDetectionManual:
+	default:
+		return fmt.Errorf("unknown turn detection mode: %q (must be %q or %q)", td.Mode, TurnDetectionServerVAD, TurnDetectionManual)
+	}
+
+	if td.Threshold < 0 || td.Threshold > 1 {
+		return fmt.Errorf("turn detection threshold must be between 0.0 and 1.0: %v", td.Threshold)
+	}
+
+	return nil
+}
+
+// SessionUpdate contains the session settings to change mid-call (empty fields are left intact)
+type SessionUpdate struct {
+	Prompt string
+	Voice  string
+	Tools  interface{}
 }
 
 func NewConfig(key string) *Config {
 	return &Config{
-		URL:   "wss://api.openai.com/v1/realtime",
-		Key:   key,
-		Model: "gpt-4o-realtime-preview-2024-10-01",
-		Voice: "alloy",
+		Provider: DefaultProvider,
+		URL:      "wss://api.openai.com/v1/realtime",
+		Key:      key,
+		Model:    "gpt-4o-realtime-preview-2024-10-01",
+		Voice:    "alloy",
+
+		ReconnectAttempts: 5,
+		SendQueueSize:     128,
+		OverflowPolicy:    OverflowDropAudio,
 	}
 }
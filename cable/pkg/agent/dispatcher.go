@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/joomcode/errorx"
+)
+
+type EventFactory = func() Event
+
+var (
+	eventTypes = map[string]EventFactory{
+		"error":                             func() Event { return &ErrorEvent{} },
+		"session.created":                   func() Event { return &SessionCreatedEvent{} },
+		"session.updated":                   func() Event { return &SessionUpdatedEvent{} },
+		"transcription_session.updated":     func() Event { return &TranscriptionSessionUpdatedEvent{} },
+		"conversation.created":              func() Event { return &ConversationCreatedEvent{} },
+		"conversation.item.created":         func() Event { return &ConversationItemCreatedEvent{} },
+		"conversation.item.retrieved":       func() Event { return &ConversationItemRetrievedEvent{} },
+		"conversation.item.truncated":       func() Event { return &ConversationItemTruncatedEvent{} },
+		"conversation.item.deleted":         func() Event { return &ConversationItemDeletedEvent{} },
+		"input_audio_buffer.committed":      func() Event { return &InputAudioBufferCommittedEvent{} },
+		"input_audio_buffer.cleared":        func() Event { return &InputAudioBufferClearedEvent{} },
+		"input_audio_buffer.speech_started": func() Event { return &SpeechStartedEvent{} },
+		"input_audio_buffer.speech_stopped": func() Event { return &SpeechStoppedEvent{} },
+		"conversation.item.input_audio_transcription.completed": func() Event {
+			return &InputAudioTranscriptionCompletedEvent{}
+		},
+		"conversation.item.input_audio_transcription.delta": func() Event {
+			return &InputAudioTranscriptionDeltaEvent{}
+		},
+		"conversation.item.input_audio_transcription.failed": func() Event {
+			return &InputAudioTranscriptionFailedEvent{}
+		},
+		"response.created":                       func() Event { return &ResponseCreatedEvent{} },
+		"response.done":                          func() Event { return &ResponseDoneEvent{} },
+		"response.output_item.added":             func() Event { return &OutputItemAddedEvent{} },
+		"response.output_item.done":              func() Event { return &OutputItemDoneEvent{} },
+		"response.content_part.added":            func() Event { return &ContentPartAddedEvent{} },
+		"response.content_part.done":             func() Event { return &ContentPartDoneEvent{} },
+		"response.text.delta":                    func() Event { return &TextDeltaEvent{} },
+		"response.text.done":                     func() Event { return &TextDoneEvent{} },
+		"response.audio_transcript.delta":        func() Event { return &AudioTranscriptDeltaEvent{} },
+		"response.audio_transcript.done":         func() Event { return &AudioTranscriptDoneEvent{} },
+		"response.audio.delta":                   func() Event { return &AudioDeltaEvent{} },
+		"response.audio.done":                    func() Event { return &AudioDoneEvent{} },
+		"response.function_call_arguments.delta": func() Event { return &FunctionCallArgumentsDeltaEvent{} },
+		"response.function_call_arguments.done":  func() Event { return &FunctionCallArgumentsDoneEvent{} },
+		"rate_limits.updated":                    func() Event { return &RateLimitsUpdatedEvent{} },
+		"output_audio_buffer.started":            func() Event { return &OutputAudioBufferStartedEvent{} },
+		"output_audio_buffer.stopped":            func() Event { return &OutputAudioBufferStoppedEvent{} },
+		"output_audio_buffer.cleared":            func() Event { return &OutputAudioBufferClearedEvent{} },
+	}
+	eventTypesMu sync.RWMutex
+)
+
+// RegisterEvent makes ParseEvent decode the server events of the given type into the struct
+// returned by the factory (e.g., for new or provider-specific events)
+func RegisterEvent(eventType string, factory EventFactory) {
+	eventTypesMu.Lock()
+	defer eventTypesMu.Unlock()
+
+	eventTypes[eventType] = factory
+}
+
+// ParseEvent turns a raw server message into a typed event;
+// events of unknown types are returned as *UnknownEvent
+func ParseEvent(msg []byte) (Event, error) {
+	var base BaseEvent
+
+	if err := json.Unmarshal(msg, &base); err != nil {
+		return nil, errorx.Decorate(err, "malformed server event")
+	}
+
+	if base.Type == "" {
+		return nil, errors.New("server event type is missing")
+	}
+
+	eventTypesMu.RLock()
+	factory, ok := eventTypes[base.Type]
+	eventTypesMu.RUnlock()
+
+	if !ok {
+		return &UnknownEvent{BaseEvent: base, Raw: json.RawMessage(msg)}, nil
+	}
+
+	ev := factory()
+
+	if err := json.Unmarshal(msg, ev); err != nil {
+		return nil, errorx.Decorate(err, "failed to parse %s event", base.Type)
+	}
+
+	return ev, nil
+}
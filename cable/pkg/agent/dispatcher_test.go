@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEvent(t *testing.T) {
+	t.Run("error", func(t *testing.T) {
+		ev, err := ParseEvent([]byte(`{"event_id":"ev_1","type":"error","error":{"type":"invalid_request_error","code":"invalid_value","message":"Invalid voice","param":"session.voice","event_id":"client_1"}}`))
+		require.NoError(t, err)
+
+		errEv, ok := ev.(*ErrorEvent)
+		require.True(t, ok)
+
+		assert.Equal(t, "ev_1", errEv.GetEventId())
+		assert.Equal(t, "session.voice", errEv.Error.Param)
+		assert.Equal(t, "client_1", errEv.Error.EventId)
+		assert.Equal(t, "invalid_request_error (invalid_value): Invalid voice", errEv.Error.Error())
+	})
+
+	t.Run("rate limits", func(t *testing.T) {
+		ev, err := ParseEvent([]byte(`{"event_id":"ev_2","type":"rate_limits.updated","rate_limits":[{"name":"requests","limit":1000,"remaining":999,"reset_seconds":60},{"name":"tokens","limit":50000,"remaining":49950,"reset_seconds":0.5}]}`))
+		require.NoError(t, err)
+
+		limits, ok := ev.(*RateLimitsUpdatedEvent)
+		require.True(t, ok)
+
+		require.Len(t, limits.RateLimits, 2)
+		assert.Equal(t, &RateLimit{Name: "tokens", Limit: 50000, Remaining: 49950, ResetSeconds: 0.5}, limits.RateLimits[1])
+	})
+
+	t.Run("function call arguments delta", func(t *testing.T) {
+		ev, err := ParseEvent([]byte(`{"event_id":"ev_3","type":"response.function_call_arguments.delta","response_id":"resp_1","item_id":"item_1","output_index":0,"call_id":"call_1","delta":"{\"per"}`))
+		require.NoError(t, err)
+
+		delta, ok := ev.(*FunctionCallArgumentsDeltaEvent)
+		require.True(t, ok)
+
+		assert.Equal(t, "call_1", delta.CallID)
+		assert.Equal(t, `{"per`, delta.Delta)
+	})
+
+	t.Run("conversation item created", func(t *testing.T) {
+		ev, err := ParseEvent([]byte(`{"event_id":"ev_4","type":"conversation.item.created","previous_item_id":"item_0","item":{"id":"item_1","object":"realtime.item","type":"message","role":"user","content":[{"type":"input_audio","transcript":null}]}}`))
+		require.NoError(t, err)
+
+		created, ok := ev.(*ConversationItemCreatedEvent)
+		require.True(t, ok)
+
+		assert.Equal(t, "item_0", created.PreviousItemId)
+		assert.Equal(t, "user", created.Item.Role)
+		assert.Equal(t, "input_audio", created.Item.Content[0].Type)
+	})
+
+	t.Run("events sharing the same shape have distinct types", func(t *testing.T) {
+		created, err := ParseEvent([]byte(`{"type":"response.created","response":{"id":"resp_1","status":"in_progress"}}`))
+		require.NoError(t, err)
+
+		done, err := ParseEvent([]byte(`{"type":"response.done","response":{"id":"resp_1","status":"completed","usage":{"total_tokens":10}}}`))
+		require.NoError(t, err)
+
+		assert.IsType(t, &ResponseCreatedEvent{}, created)
+		assert.IsType(t, &ResponseDoneEvent{}, done)
+		assert.Equal(t, 10, done.(*ResponseDoneEvent).Response.Usage.TotalTokens)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		raw := `{"event_id":"ev_5","type":"response.something_new","foo":"bar"}`
+
+		ev, err := ParseEvent([]byte(raw))
+		require.NoError(t, err)
+
+		unknown, ok := ev.(*UnknownEvent)
+		require.True(t, ok)
+
+		assert.Equal(t, "response.something_new", unknown.GetType())
+		assert.JSONEq(t, raw, string(unknown.Raw))
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := ParseEvent([]byte(`not a json`))
+		require.Error(t, err)
+
+		_, err = ParseEvent([]byte(`{"event_id":"ev_6"}`))
+		require.Error(t, err)
+
+		_, err = ParseEvent([]byte(`{"type":"rate_limits.updated","rate_limits":"none"}`))
+		require.Error(t, err)
+	})
+}
+
+type customEvent struct {
+	Foo string `json:"foo"`
+	BaseEvent
+}
+
+func TestRegisterEvent(t *testing.T) {
+	RegisterEvent("test.custom", func() Event { return &customEvent{} })
+
+	defer func() {
+		eventTypesMu.Lock()
+		delete(eventTypes, "test.custom")
+		eventTypesMu.Unlock()
+	}()
+
+	ev, err := ParseEvent([]byte(`{"event_id":"ev_1","type":"test.custom","foo":"bar"}`))
+	require.NoError(t, err)
+
+	custom, ok := ev.(*customEvent)
+	require.True(t, ok)
+
+	assert.Equal(t, "bar", custom.Foo)
+	assert.Equal(t, "test.custom", custom.GetType())
+}
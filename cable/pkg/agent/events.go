@@ -1,8 +1,39 @@
 package agent
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // Struct representing various OpenAI events
 // See https://platform.openai.com/docs/api-reference/realtime-server-events
 
+// Event is a typed server event (see ParseEvent)
+type Event interface {
+	GetType() string
+	GetEventId() string
+}
+
+// BaseEvent contains the fields common for all server events
+type BaseEvent struct {
+	EventId string `json:"event_id"`
+	Type    string `json:"type"`
+}
+
+func (ev *BaseEvent) GetType() string {
+	return ev.Type
+}
+
+func (ev *BaseEvent) GetEventId() string {
+	return ev.EventId
+}
+
+// UnknownEvent represents a server event with no registered type
+type UnknownEvent struct {
+	BaseEvent
+	Raw json.RawMessage `json:"-"`
+}
+
 type Item struct {
 	Id     string `json:"id,omitempty"`
 	Object string `json:"object,omitempty"`
@@ -14,6 +45,59 @@ type Item struct {
 	Name      string `json:"name,omitempty"`
 	Arguments string `json:"arguments,omitempty"`
 	Output    string `json:"output,omitempty"`
+	// Message fields
+	Content []*ContentPart `json:"content,omitempty"`
+}
+
+type ContentPart struct {
+	Type       string `json:"type"`
+	Text       string `json:"text,omitempty"`
+	Audio      string `json:"audio,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+}
+
+// ErrorDetails describes an error reported by the server
+type ErrorDetails struct {
+	Type    string `json:"type,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	Param   string `json:"param,omitempty"`
+	// ID of the client event that caused the error (if any)
+	EventId string `json:"event_id,omitempty"`
+}
+
+func (e *ErrorDetails) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s (%s): %s", e.Type, e.Code, e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Type, e.Message)
+}
+
+type Session struct {
+	Id                string   `json:"id,omitempty"`
+	Object            string   `json:"object,omitempty"`
+	Model             string   `json:"model,omitempty"`
+	Modalities        []string `json:"modalities,omitempty"`
+	Instructions      string   `json:"instructions,omitempty"`
+	Voice             string   `json:"voice,omitempty"`
+	InputAudioFormat  string   `json:"input_audio_format,omitempty"`
+	OutputAudioFormat string   `json:"output_audio_format,omitempty"`
+	Temperature       float64  `json:"temperature,omitempty"`
+	// The following settings are kept raw, since they're polymorphic (e.g., turn_detection could be null)
+	InputAudioTranscription json.RawMessage `json:"input_audio_transcription,omitempty"`
+	TurnDetection           json.RawMessage `json:"turn_detection,omitempty"`
+	Tools                   json.RawMessage `json:"tools,omitempty"`
+	ToolChoice              json.RawMessage `json:"tool_choice,omitempty"`
+	MaxResponseOutputTokens json.RawMessage `json:"max_response_output_tokens,omitempty"`
+}
+
+type RateLimit struct {
+	// "requests" or "tokens"
+	Name         string  `json:"name"`
+	Limit        int     `json:"limit"`
+	Remaining    int     `json:"remaining"`
+	ResetSeconds float64 `json:"reset_seconds"`
 }
 
 type Usage struct {
@@ -31,8 +115,34 @@ type Usage struct {
 	} `json:"output_token_details"`
 }
 
+// UsageTotals accumulates token usage over multiple responses
+type UsageTotals struct {
+	Responses         int `json:"responses"`
+	TotalTokens       int `json:"total_tokens"`
+	InputTokens       int `json:"input_tokens"`
+	OutputTokens      int `json:"output_tokens"`
+	CachedTokens      int `json:"cached_tokens"`
+	InputTextTokens   int `json:"input_text_tokens"`
+	InputAudioTokens  int `json:"input_audio_tokens"`
+	OutputTextTokens  int `json:"output_text_tokens"`
+	OutputAudioTokens int `json:"output_audio_tokens"`
+}
+
+func (t *UsageTotals) Add(u *Usage) {
+	t.Responses++
+	t.TotalTokens += u.TotalTokens
+	t.InputTokens += u.InputTokens
+	t.OutputTokens += u.OutputTokens
+	t.CachedTokens += u.InputTokenDetails.CachedTokens
+	t.InputTextTokens += u.InputTokenDetails.TextTokens
+	t.InputAudioTokens += u.InputTokenDetails.AudioTokens
+	t.OutputTextTokens += u.OutputTokenDetails.TextTokens
+	t.OutputAudioTokens += u.OutputTokenDetails.AudioTokens
+}
+
 type Response struct {
 	ID            string `json:"id,omitempty"`
+	Object        string `json:"object,omitempty"`
 	Status        string `json:"status,omitempty"`
 	StatusDetails struct {
 		Type   string `json:"type,omitempty"`
@@ -43,28 +153,97 @@ type Response struct {
 			Message string `json:"message,omitempty"`
 		} `json:"error,omitempty"`
 	} `json:"status_details,omitempty"`
-	Usage *Usage `json:"usage,omitempty"`
+	Output []*Item `json:"output,omitempty"`
+	Usage  *Usage  `json:"usage,omitempty"`
+}
+
+type ErrorEvent struct {
+	Error ErrorDetails `json:"error"`
+	BaseEvent
+}
+
+type SessionEvent struct {
+	Session *Session `json:"session"`
+	BaseEvent
+}
+
+type SessionCreatedEvent struct {
+	SessionEvent
+}
+
+type SessionUpdatedEvent struct {
+	SessionEvent
+}
+
+type TranscriptionSessionUpdatedEvent struct {
+	Session json.RawMessage `json:"session"`
+	BaseEvent
+}
+
+type ConversationCreatedEvent struct {
+	Conversation struct {
+		Id     string `json:"id"`
+		Object string `json:"object"`
+	} `json:"conversation"`
+	BaseEvent
+}
+
+type ConversationItemCreatedEvent struct {
+	PreviousItemId string `json:"previous_item_id"`
+	Item           *Item  `json:"item"`
+	BaseEvent
+}
+
+type ConversationItemRetrievedEvent struct {
+	Item *Item `json:"item"`
+	BaseEvent
+}
+
+type RateLimitsUpdatedEvent struct {
+	RateLimits []*RateLimit `json:"rate_limits"`
+	BaseEvent
 }
 
 type ResponseEvent struct {
-	EventId  string `json:"event_id"`
-	Type     string `json:"type"`
-	Response *Response
+	Response *Response `json:"response"`
+	BaseEvent
+}
+
+type ResponseCreatedEvent struct {
+	ResponseEvent
+}
+
+type ResponseDoneEvent struct {
+	ResponseEvent
 }
 
 type ItemEvent struct {
-	EventId      string `json:"event_id"`
-	Type         string `json:"type"`
 	ItemId       string `json:"item_id"`
 	ContentIndex int    `json:"content_index"`
+	BaseEvent
 }
 
 func (ev *ItemEvent) GetItemId() string {
 	return ev.ItemId
 }
 
-func (ev *ItemEvent) GetType() string {
-	return ev.Type
+type ConversationItemTruncatedEvent struct {
+	AudioEndMs int `json:"audio_end_ms"`
+	ItemEvent
+}
+
+type ConversationItemDeletedEvent struct {
+	ItemEvent
+}
+
+type InputAudioBufferCommittedEvent struct {
+	PreviousItemId string `json:"previous_item_id"`
+	ItemId         string `json:"item_id"`
+	BaseEvent
+}
+
+type InputAudioBufferClearedEvent struct {
+	BaseEvent
 }
 
 type OutputItemEvent struct {
@@ -94,6 +273,16 @@ func (ev *InputAudioTranscriptionCompletedEvent) GetTranscript() string {
 
 var _ TranscriptEvent = (*InputAudioTranscriptionCompletedEvent)(nil)
 
+type InputAudioTranscriptionDeltaEvent struct {
+	Delta string `json:"delta"`
+	ItemEvent
+}
+
+type InputAudioTranscriptionFailedEvent struct {
+	Error ErrorDetails `json:"error"`
+	ItemEvent
+}
+
 type AudioTranscriptDeltaEvent struct {
 	Delta string `json:"delta"`
 	OutputItemEvent
@@ -129,7 +318,87 @@ type AudioDeltaEvent struct {
 	OutputItemEvent
 }
 
+type AudioDoneEvent struct {
+	OutputItemEvent
+}
+
+type TextDeltaEvent struct {
+	Delta string `json:"delta"`
+	OutputItemEvent
+}
+
+type TextDoneEvent struct {
+	Text string `json:"text"`
+	OutputItemEvent
+}
+
+type ContentPartEvent struct {
+	Part *ContentPart `json:"part"`
+	OutputItemEvent
+}
+
+type ContentPartAddedEvent struct {
+	ContentPartEvent
+}
+
+type ContentPartDoneEvent struct {
+	ContentPartEvent
+}
+
+type OutputItemAddedEvent struct {
+	Item *Item `json:"item"`
+	OutputItemEvent
+}
+
 type OutputItemDoneEvent struct {
 	Item *Item `json:"item"`
 	OutputItemEvent
 }
+
+type FunctionCallArgumentsDeltaEvent struct {
+	ResponseId  string `json:"response_id"`
+	ItemId      string `json:"item_id"`
+	OutputIndex int    `json:"output_index"`
+	CallID      string `json:"call_id"`
+	Delta       string `json:"delta"`
+	BaseEvent
+}
+
+type FunctionCallArgumentsDoneEvent struct {
+	ResponseId  string `json:"response_id"`
+	ItemId      string `json:"item_id"`
+	OutputIndex int    `json:"output_index"`
+	CallID      string `json:"call_id"`
+	Arguments   string `json:"arguments"`
+	BaseEvent
+}
+
+type SpeechStartedEvent struct {
+	ItemId       string `json:"item_id"`
+	AudioStartMs int    `json:"audio_start_ms"`
+	BaseEvent
+}
+
+type SpeechStoppedEvent struct {
+	ItemId     string `json:"item_id"`
+	AudioEndMs int    `json:"audio_end_ms"`
+	BaseEvent
+}
+
+// Output audio buffer events are only sent for WebRTC connections
+type OutputAudioBufferEvent struct {
+	ResponseId string `json:"response_id"`
+	BaseEvent
+}
+
+type OutputAudioBufferStartedEvent struct {
+	OutputAudioBufferEvent
+}
+
+type OutputAudioBufferStoppedEvent struct {
+	OutputAudioBufferEvent
+}
+
+type OutputAudioBufferClearedEvent struct {
+	OutputAudioBufferEvent
+}
@@ -0,0 +1,704 @@
+package agent
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/anycable/anycable-go/logger"
+	"github.com/anycable/anycable-go/utils"
+	"github.com/gorilla/websocket"
+	"github.com/joomcode/errorx"
+)
+
+const (
+	reconnectBackoff    = 250 * time.Millisecond
+	maxReconnectBackoff = 5 * time.Second
+)
+
+func init() {
+	RegisterProvider("openai", func(c *Config, l *slog.Logger) Provider {
+		return NewOpenAIProvider(c, l)
+	})
+}
+
+// OpenAIProvider connects to OpenAI realtime API via WebSocket
+type OpenAIProvider struct {
+	conf *Config
+
+	conn  *websocket.Conn
+	queue *sendQueue
+
+	callbacks *Callbacks
+
+	log *slog.Logger
+
+	// ID of the response being generated (if any)
+	activeResponseID string
+	// Completed conversation items to replay on reconnect
+	history []*historyItem
+	// Assistant's audio received (ms) and played (ms, for the truncated items) per item
+	audioMs     map[string]int
+	truncatedMs map[string]int
+
+	cancelFn context.CancelFunc
+	connMu   sync.RWMutex
+	mu       sync.Mutex
+
+	done     chan struct{}
+	err      error
+	doneOnce sync.Once
+}
+
+var _ Provider = (*OpenAIProvider)(nil)
+
+// historyItem is a conversation item to replay on reconnect
+type historyItem struct {
+	item *Item
+	// Server-side ID of the item (if it's been created by the server)
+	id string
+	// The full assistant's transcript (the item's text could be truncated to the played part)
+	transcript string
+}
+
+func NewOpenAIProvider(c *Config, l *slog.Logger) *OpenAIProvider {
+	return &OpenAIProvider{
+		conf:        c,
+		queue:       newSendQueue(c.SendQueueSize, c.OverflowPolicy),
+		audioMs:     make(map[string]int),
+		truncatedMs: make(map[string]int),
+		done:        make(chan struct{}),
+		callbacks:   &Callbacks{},
+		log:         l.With("provider", "openai"),
+	}
+}
+
+// Connect starts the OpenAI WebSocket connection.
+func (p *OpenAIProvider) Connect(ctx context.Context, callbacks *Callbacks) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	conn, err := p.dial(ctx)
+
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	p.connMu.Lock()
+	p.cancelFn = cancel
+	p.conn = conn
+	p.callbacks = callbacks
+	p.connMu.Unlock()
+
+	p.log.Debug("connected to OpenAI WebSocket")
+
+	if err := p.configure(conn); err != nil {
+		cancel()
+		conn.Close()
+		return errorx.Decorate(err, "could not configure OpenAI session")
+	}
+
+	go p.run(ctx, conn)
+
+	return nil
+}
+
+func (p *OpenAIProvider) dial(ctx context.Context) (*websocket.Conn, error) {
+	url := p.conf.URL + "?model=" + p.conf.Model
+	header := http.Header{
+		"Authorization": []string{"Bearer " + p.conf.Key},
+		"OpenAI-Beta":   []string{"realtime=v1"},
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+
+	if err != nil {
+		return nil, errorx.Decorate(err, "could not dial OpenAI WebSocket")
+	}
+
+	return conn, nil
+}
+
+// configure sends session.update event to configure the session
+// and replays the conversation history (if any).
+// Messages are written directly to the connection, so they go before everything queued.
+func (p *OpenAIProvider) configure(conn *websocket.Conn) error {
+	if err := conn.WriteMessage(websocket.TextMessage, p.sessionUpdateMessage()); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	history := make([]*Item, len(p.history))
+
+	for i, h := range p.history {
+		history[i] = h.item
+	}
+	p.mu.Unlock()
+
+	if len(history) > 0 {
+		p.log.Debug("replaying conversation", "items", len(history))
+	}
+
+	for _, item := range history {
+		// The assistant's message hasn't been heard at all
+		if item == nil {
+			continue
+		}
+
+		msg := struct {
+			Type string `json:"type"`
+			Item *Item  `json:"item"`
+		}{"conversation.item.create", item}
+
+		if err := conn.WriteMessage(websocket.TextMessage, utils.ToJSON(msg)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// run serves the connection and reconnects if it's lost
+func (p *OpenAIProvider) run(ctx context.Context, conn *websocket.Conn) {
+	for {
+		connCtx, cancel := context.WithCancel(ctx)
+		writerDone := make(chan struct{})
+
+		go func() {
+			defer close(writerDone)
+			p.writeMessages(connCtx, conn)
+		}()
+
+		p.readMessages(conn)
+
+		cancel()
+		conn.Close()
+
+		// Make sure the failed message (if any) is back in the queue before we reconnect
+		<-writerDone
+
+		// Closed by us
+		if ctx.Err() != nil {
+			return
+		}
+
+		p.mu.Lock()
+		// The response has been lost with the connection
+		p.activeResponseID = ""
+		p.mu.Unlock()
+
+		conn = p.reconnect(ctx)
+
+		if conn == nil {
+			p.terminate(errors.New("connection to OpenAI is lost"))
+			return
+		}
+	}
+}
+
+func (p *OpenAIProvider) reconnect(ctx context.Context) *websocket.Conn {
+	backoff := reconnectBackoff
+
+	for attempt := 1; attempt <= p.conf.ReconnectAttempts; attempt++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxReconnectBackoff)
+
+		p.log.Info("reconnecting to OpenAI WebSocket", "attempt", attempt)
+
+		conn, err := p.dial(ctx)
+
+		if err != nil {
+			p.log.Warn("could not reconnect to OpenAI WebSocket", "attempt", attempt, "err", err)
+			continue
+		}
+
+		if err := p.configure(conn); err != nil {
+			p.log.Warn("could not restore OpenAI session", "attempt", attempt, "err", err)
+			conn.Close()
+			continue
+		}
+
+		p.connMu.Lock()
+		p.conn = conn
+		p.connMu.Unlock()
+
+		// Close could be called while we were reconnecting
+		if ctx.Err() != nil {
+			conn.Close()
+			return nil
+		}
+
+		p.log.Info("reconnected to OpenAI WebSocket", "attempt", attempt)
+
+		return conn
+	}
+
+	p.log.Error("could not reconnect to OpenAI WebSocket, giving up", "attempts", p.conf.ReconnectAttempts)
+
+	return nil
+}
+
+func (p *OpenAIProvider) sessionUpdateMessage() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	session := map[string]interface{}{
+		"input_audio_format":  "g711_ulaw",
+		"output_audio_format": "g711_ulaw",
+		"input_audio_transcription": map[string]string{
+			"model": "whisper-1",
+		},
+	}
+
+	if p.conf.Voice != "" {
+		session["voice"] = p.conf.Voice
+	}
+
+	if p.conf.Prompt != "" {
+		session["instructions"] = p.conf.Prompt
+	}
+
+	if p.conf.Tools != nil {
+		session["tools"] = p.conf.Tools
+	}
+
+	if td := p.conf.TurnDetection; td != nil {
+		session["turn_detection"] = turnDetectionSettings(td)
+	}
+
+	return utils.ToJSON(map[string]interface{}{"type": "session.update", "session": session})
+}
+
+// turnDetectionSettings returns the turn_detection session field (nil disables turn detection)
+func turnDetectionSettings(td *TurnDetection) map[string]interface{} {
+	if td.Manual() {
+		return nil
+	}
+
+	settings := map[string]interface{}{"type": TurnDetectionServerVAD}
+
+	if td.Mode != "" {
+		settings["type"] = td.Mode
+	}
+
+	if td.Threshold > 0 {
+		settings["threshold"] = td.Threshold
+	}
+
+	if td.PrefixPaddingMs > 0 {
+		settings["prefix_padding_ms"] = td.PrefixPaddingMs
+	}
+
+	if td.SilenceDurationMs > 0 {
+		settings["silence_duration_ms"] = td.SilenceDurationMs
+	}
+
+	return settings
+}
+
+// UpdateSession applies the changes to the config (so they're restored on reconnect)
+// and sends the session.update event
+func (p *OpenAIProvider) UpdateSession(update *SessionUpdate) error {
+	p.mu.Lock()
+
+	if update.Prompt != "" {
+		p.conf.Prompt = update.Prompt
+	}
+
+	if update.Voice != "" {
+		p.conf.Voice = update.Voice
+	}
+
+	if update.Tools != nil {
+		p.conf.Tools = update.Tools
+	}
+
+	p.mu.Unlock()
+
+	p.log.Debug("updating session")
+
+	return p.sendMsg(p.sessionUpdateMessage())
+}
+
+func (p *OpenAIProvider) SendAudio(audio []byte) error {
+	// The caller may reuse the buffer
+	return p.send(&outboundMsg{audio: append([]byte(nil), audio...)})
+}
+
+func (p *OpenAIProvider) SendFunctionCallResult(callID string, data string) error {
+	item := &Item{Type: "function_call_output", CallID: callID, Output: data}
+
+	msg := struct {
+		Type string `json:"type"`
+		Item *Item  `json:"item"`
+	}{"conversation.item.create", item}
+
+	encoded := utils.ToJSON(msg)
+
+	p.log.Warn("sending function call result", "data", string(encoded))
+
+	if err := p.send(&outboundMsg{data: encoded, item: item}); err != nil {
+		return err
+	}
+
+	// Send `response.create` message right away to trigger model inference
+	return p.sendMsg([]byte(`{"type":"response.create"}`))
+}
+
+func (p *OpenAIProvider) SendUserMessage(text string) error {
+	item := &Item{Type: "message", Role: "user", Content: []*ContentPart{{Type: "input_text", Text: text}}}
+
+	msg := struct {
+		Type string `json:"type"`
+		Item *Item  `json:"item"`
+	}{"conversation.item.create", item}
+
+	p.log.Debug("sending user message", "text", text)
+
+	if err := p.send(&outboundMsg{data: utils.ToJSON(msg), item: item}); err != nil {
+		return err
+	}
+
+	return p.sendMsg([]byte(`{"type":"response.create"}`))
+}
+
+func (p *OpenAIProvider) CommitInput() error {
+	p.log.Debug("committing input")
+
+	if err := p.sendMsg([]byte(`{"type":"input_audio_buffer.commit"}`)); err != nil {
+		return err
+	}
+
+	return p.sendMsg([]byte(`{"type":"response.create"}`))
+}
+
+func (p *OpenAIProvider) CreateResponse(instructions string) error {
+	msg := struct {
+		Type     string `json:"type"`
+		Response struct {
+			Instructions string `json:"instructions,omitempty"`
+		} `json:"response"`
+	}{Type: "response.create"}
+
+	msg.Response.Instructions = instructions
+
+	return p.sendMsg(utils.ToJSON(msg))
+}
+
+func (p *OpenAIProvider) CancelResponse() error {
+	p.mu.Lock()
+	id := p.activeResponseID
+	p.activeResponseID = ""
+	p.mu.Unlock()
+
+	if id == "" {
+		return nil
+	}
+
+	p.log.Debug("cancelling response", "id", id)
+
+	return p.sendMsg([]byte(`{"type":"response.cancel"}`))
+}
+
+func (p *OpenAIProvider) TruncateItem(itemID string, audioEndMs int) error {
+	msg := struct {
+		Type         string `json:"type"`
+		ItemID       string `json:"item_id"`
+		ContentIndex int    `json:"content_index"`
+		AudioEndMs   int    `json:"audio_end_ms"`
+	}{"conversation.item.truncate", itemID, 0, audioEndMs}
+
+	p.log.Debug("truncating item", "id", itemID, "audio_end_ms", audioEndMs)
+
+	p.truncateHistory(itemID, audioEndMs)
+
+	return p.sendMsg(utils.ToJSON(msg))
+}
+
+func (p *OpenAIProvider) Close() {
+	p.terminate(nil)
+}
+
+func (p *OpenAIProvider) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *OpenAIProvider) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.err
+}
+
+// terminate stops the provider (the error is nil when it's closed explicitly)
+func (p *OpenAIProvider) terminate(err error) {
+	p.doneOnce.Do(func() {
+		if err != nil {
+			p.log.Error("agent failed", "err", err)
+		}
+
+		if dropped := p.queue.droppedAudio(); dropped > 0 {
+			p.log.Warn("caller's audio has been dropped due to the send queue overflow", "chunks", dropped)
+		}
+
+		p.mu.Lock()
+		p.err = err
+		p.mu.Unlock()
+
+		p.queue.close()
+
+		p.connMu.RLock()
+		if p.cancelFn != nil {
+			p.cancelFn()
+		}
+
+		if p.conn != nil {
+			p.conn.Close()
+		}
+		p.connMu.RUnlock()
+
+		close(p.done)
+	})
+}
+
+func (p *OpenAIProvider) readMessages(conn *websocket.Conn) {
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			p.log.Error("could not read message from OpenAI WebSocket", "err", err)
+			return
+		}
+
+		p.log.Debug("received message from OpenAI WebSocket", "msg", logger.CompactValue(string(msg)))
+
+		ev, err := ParseEvent(msg)
+
+		if err != nil {
+			p.log.Error("could not parse message from OpenAI WebSocket", "err", err)
+			continue
+		}
+
+		p.handleEvent(ev)
+
+		if p.callbacks.Event != nil {
+			p.callbacks.Event(ev)
+		}
+	}
+}
+
+func (p *OpenAIProvider) handleEvent(ev Event) {
+	switch ev := ev.(type) {
+	case *SpeechStartedEvent:
+		p.handleSpeechStarted(ev)
+	case *InputAudioTranscriptionCompletedEvent:
+		p.rememberTranscript(ev)
+		p.handleTranscript(ev)
+	case *InputAudioTranscriptionFailedEvent:
+		p.log.Warn("input audio transcription failed", "id", ev.ItemId, "error", ev.Error.Error())
+	case *ResponseCreatedEvent:
+		p.mu.Lock()
+		p.activeResponseID = ev.Response.ID
+		p.mu.Unlock()
+	case *AudioDeltaEvent:
+		p.trackAudio(ev)
+		p.handleAudio(ev)
+	case *AudioTranscriptDeltaEvent:
+		p.handleTranscript(ev)
+	case *AudioTranscriptDoneEvent:
+		p.rememberTranscript(ev)
+		p.handleTranscript(ev)
+	case *OutputItemDoneEvent:
+		if ev.Item.Type == "function_call" {
+			p.remember(&historyItem{item: &Item{Type: "function_call", CallID: ev.Item.CallID, Name: ev.Item.Name, Arguments: ev.Item.Arguments}, id: ev.Item.Id})
+			p.handleFunctionCall(ev.Item)
+		}
+	case *ResponseDoneEvent:
+		p.mu.Lock()
+		if p.activeResponseID == ev.Response.ID {
+			p.activeResponseID = ""
+		}
+		p.mu.Unlock()
+
+		// Log errors
+		if ev.Response.Status == "failed" {
+			p.log.Error("request failed", "error", ev.Response.StatusDetails.Error)
+		}
+
+		if ev.Response.Usage != nil {
+			p.handleUsage(ev.Response.Usage)
+		}
+	case *RateLimitsUpdatedEvent:
+		for _, limit := range ev.RateLimits {
+			p.log.Debug("rate limit updated", "name", limit.Name, "limit", limit.Limit, "remaining", limit.Remaining, "reset_seconds", limit.ResetSeconds)
+		}
+	case *ErrorEvent:
+		p.log.Error("server error", "type", ev.Error.Type, "code", ev.Error.Code, "message", ev.Error.Message, "param", ev.Error.Param, "event_id", ev.Error.EventId)
+	case *UnknownEvent:
+		p.log.Warn("unhandled message type", "type", ev.Type)
+	}
+}
+
+func (p *OpenAIProvider) writeMessages(ctx context.Context, conn *websocket.Conn) {
+	for {
+		msg := p.queue.pop(ctx.Done())
+
+		if msg == nil {
+			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+
+		if err := conn.WriteMessage(websocket.TextMessage, msg.encode()); err != nil {
+			p.log.Error("could not write message to OpenAI WebSocket", "err", err)
+			// The message is sent after reconnecting
+			p.queue.requeue(msg)
+			// Make sure the reader notices the failure and triggers reconnection
+			conn.Close()
+			return
+		}
+
+		if msg.item != nil {
+			p.remember(&historyItem{item: msg.item})
+		}
+	}
+}
+
+func (p *OpenAIProvider) sendMsg(msg []byte) error {
+	return p.send(&outboundMsg{data: msg})
+}
+
+// send enqueues the message without blocking; the agent fails if the queue is full
+// and the message couldn't be enqueued according to the overflow policy
+func (p *OpenAIProvider) send(msg *outboundMsg) error {
+	err := p.queue.push(msg)
+
+	if errors.Is(err, ErrSendQueueFull) {
+		p.terminate(err)
+	}
+
+	return err
+}
+
+// remember adds the item to the conversation history
+func (p *OpenAIProvider) remember(h *historyItem) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if h.transcript != "" {
+		if played, ok := p.truncatedMs[h.id]; ok {
+			h.item = assistantMessage(truncateTranscript(h.transcript, played, p.audioMs[h.id]))
+		}
+	}
+
+	p.history = append(p.history, h)
+}
+
+// truncateHistory cuts the assistant's transcript to the part heard by the user
+func (p *OpenAIProvider) truncateHistory(itemID string, audioEndMs int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.truncatedMs[itemID] = audioEndMs
+
+	for _, h := range p.history {
+		if h.id == itemID && h.transcript != "" {
+			// Items are replaced, not modified, since they could be being replayed right now
+			h.item = assistantMessage(truncateTranscript(h.transcript, audioEndMs, p.audioMs[itemID]))
+		}
+	}
+}
+
+func (p *OpenAIProvider) trackAudio(ev *AudioDeltaEvent) {
+	// Base64-encoded 8kHz μ-law
+	size := base64.StdEncoding.DecodedLen(len(ev.Delta)) - strings.Count(ev.Delta, "=")
+
+	p.mu.Lock()
+	p.audioMs[ev.ItemId] += size / 8
+	p.mu.Unlock()
+}
+
+func (p *OpenAIProvider) rememberTranscript(ev TranscriptEvent) {
+	text := ev.GetTranscript()
+
+	if text == "" {
+		return
+	}
+
+	// Audio can't be replayed, so we restore the conversation from transcripts
+	if ev.GetRole() == "assistant" {
+		p.remember(&historyItem{item: assistantMessage(text), id: ev.GetItemId(), transcript: text})
+		return
+	}
+
+	p.remember(&historyItem{
+		item: &Item{Type: "message", Role: ev.GetRole(), Content: []*ContentPart{{Type: "input_text", Text: text}}},
+		id:   ev.GetItemId(),
+	})
+}
+
+func assistantMessage(text string) *Item {
+	if text == "" {
+		return nil
+	}
+
+	return &Item{Type: "message", Role: "assistant", Content: []*ContentPart{{Type: "text", Text: text}}}
+}
+
+// truncateTranscript returns the beginning of the transcript proportional to the played audio
+// (cut at a word boundary)
+func truncateTranscript(text string, playedMs int, totalMs int) string {
+	if totalMs <= 0 || playedMs >= totalMs {
+		return text
+	}
+
+	runes := []rune(text)
+	cut := len(runes) * playedMs / totalMs
+
+	for cut > 0 && cut < len(runes) && !unicode.IsSpace(runes[cut]) {
+		cut--
+	}
+
+	return strings.TrimSpace(string(runes[:cut]))
+}
+
+func (p *OpenAIProvider) handleTranscript(ev TranscriptEvent) {
+	if p.callbacks.Transcript != nil {
+		p.callbacks.Transcript(ev.GetRole(), ev.GetTranscript(), ev.GetItemId())
+	}
+}
+
+func (p *OpenAIProvider) handleAudio(ev *AudioDeltaEvent) {
+	if p.callbacks.Audio != nil {
+		p.callbacks.Audio(ev.Delta, ev.ItemId)
+	}
+}
+
+func (p *OpenAIProvider) handleFunctionCall(item *Item) {
+	if p.callbacks.FunctionCall != nil {
+		p.callbacks.FunctionCall(item.Name, item.Arguments, item.CallID)
+	}
+}
+
+func (p *OpenAIProvider) handleUsage(usage *Usage) {
+	if p.callbacks.Usage != nil {
+		p.callbacks.Usage(usage)
+	}
+}
+
+func (p *OpenAIProvider) handleSpeechStarted(ev *SpeechStartedEvent) {
+	p.log.Debug("speech started", "id", ev.ItemId, "audio_start_ms", ev.AudioStartMs)
+
+	if p.callbacks.SpeechStarted != nil {
+		p.callbacks.SpeechStarted(ev.ItemId)
+	}
+}
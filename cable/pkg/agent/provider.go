@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// Callbacks are used by providers to notify the agent about the incoming events
+type Callbacks struct {
+	Transcript    TranscriptHandler
+	Audio         AudioHandler
+	FunctionCall  FunctionHandler
+	SpeechStarted SpeechStartedHandler
+	Usage         func(usage *Usage)
+	// Event is called for every server event (after it's been handled by the provider)
+	Event EventHandler
+}
+
+// Provider represents a realtime speech-to-speech LLM backend.
+// Audio is always passed as 8kHz μ-law (raw bytes for input, Base64-encoded for output).
+type Provider interface {
+	// Connect establishes a connection to the backend and configures the session
+	Connect(ctx context.Context, callbacks *Callbacks) error
+	// SendAudio sends a chunk of the caller's audio
+	SendAudio(audio []byte) error
+	// SendFunctionCallResult sends the result of the function call and requests a response
+	SendFunctionCallResult(callID string, output string) error
+	// SendUserMessage adds a text message from the user to the conversation and requests a response
+	SendUserMessage(text string) error
+	// UpdateSession changes the session settings (prompt, voice, tools) mid-call
+	UpdateSession(update *SessionUpdate) error
+	// CommitInput commits the caller's audio as a user message and requests a response (when turn detection is disabled)
+	CommitInput() error
+	// CreateResponse asks the model to respond following the instructions (e.g., to say goodbye)
+	CreateResponse(instructions string) error
+	// CancelResponse cancels the in-flight response (if any)
+	CancelResponse() error
+	// TruncateItem notifies the backend that only the first audioEndMs of the assistant's item
+	// have been heard by the user
+	TruncateItem(itemID string, audioEndMs int) error
+	// Close terminates the connection
+	Close()
+	// Done is closed when the provider stops (closed explicitly or failed)
+	Done() <-chan struct{}
+	// Err returns the reason the provider has failed (nil if it's running or has been closed explicitly)
+	Err() error
+}
+
+type ProviderFactory = func(c *Config, l *slog.Logger) Provider
+
+const DefaultProvider = "openai"
+
+var (
+	providers   = make(map[string]ProviderFactory)
+	providersMu sync.RWMutex
+)
+
+// RegisterProvider makes a provider available by the given name
+func RegisterProvider(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	providers[name] = factory
+}
+
+// Providers returns the names of the registered providers
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+
+	names := make([]string, 0, len(providers))
+
+	for name := range providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+func newProvider(c *Config, l *slog.Logger) (Provider, error) {
+	name := c.Provider
+
+	if name == "" {
+		name = DefaultProvider
+	}
+
+	providersMu.RLock()
+	factory, ok := providers[name]
+	providersMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", name)
+	}
+
+	return factory(c, l), nil
+}
@@ -0,0 +1,170 @@
+package agent
+
+import (
+	"encoding/base64"
+	"errors"
+	"sync"
+)
+
+// Outbound queue overflow policies
+const (
+	// Drop the oldest caller's audio (other messages are never dropped)
+	OverflowDropAudio = "drop_audio"
+	// Terminate the agent
+	OverflowFail = "fail"
+)
+
+// Max size of the coalesced audio chunk (5s of 8kHz μ-law)
+const maxAudioAppend = 8000 * 5
+
+var (
+	ErrClosed        = errors.New("agent is closed")
+	ErrSendQueueFull = errors.New("send queue is full")
+)
+
+type outboundMsg struct {
+	// Caller's audio (consecutive chunks are sent as a single input_audio_buffer.append event)
+	audio []byte
+	// Encoded event
+	data []byte
+	// Conversation item created by the event (added to the history once the event is sent)
+	item *Item
+}
+
+func (m *outboundMsg) encode() []byte {
+	if m.audio != nil {
+		return []byte(`{"type":"input_audio_buffer.append","audio":"` + base64.StdEncoding.EncodeToString(m.audio) + `"}`)
+	}
+
+	return m.data
+}
+
+// sendQueue is a bounded queue of outbound messages; pushing never blocks
+type sendQueue struct {
+	size   int
+	policy string
+
+	msgs    []*outboundMsg
+	closed  bool
+	dropped int
+	notify  chan struct{}
+	mu      sync.Mutex
+}
+
+func newSendQueue(size int, policy string) *sendQueue {
+	return &sendQueue{size: size, policy: policy, notify: make(chan struct{}, 1)}
+}
+
+// push adds the message to the queue; it returns ErrSendQueueFull if the queue is full
+// and no audio could be dropped (according to the policy)
+func (q *sendQueue) push(msg *outboundMsg) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+
+	if msg.audio != nil && len(q.msgs) > 0 {
+		last := q.msgs[len(q.msgs)-1]
+
+		if last.audio != nil && len(last.audio)+len(msg.audio) <= maxAudioAppend {
+			last.audio = append(last.audio, msg.audio...)
+			return nil
+		}
+	}
+
+	if len(q.msgs) >= q.size && (q.policy != OverflowDropAudio || !q.dropOldestAudio()) {
+		return ErrSendQueueFull
+	}
+
+	q.msgs = append(q.msgs, msg)
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// pop waits for the next message; it returns nil when the queue is closed or the done channel is closed
+func (q *sendQueue) pop(done <-chan struct{}) *outboundMsg {
+	for {
+		q.mu.Lock()
+
+		if len(q.msgs) > 0 {
+			msg := q.msgs[0]
+			q.msgs[0] = nil
+			q.msgs = q.msgs[1:]
+			q.mu.Unlock()
+
+			return msg
+		}
+
+		closed := q.closed
+		q.mu.Unlock()
+
+		if closed {
+			return nil
+		}
+
+		select {
+		case <-q.notify:
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// requeue puts the message back to the head of the queue (e.g., when it couldn't be sent);
+// the size limit is not checked, since the message has been already accounted for
+func (q *sendQueue) requeue(msg *outboundMsg) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	q.msgs = append([]*outboundMsg{msg}, q.msgs...)
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// close makes the queue reject new messages; the pending ones are discarded
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.msgs = nil
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// droppedAudio returns the number of dropped audio messages
+func (q *sendQueue) droppedAudio() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.dropped
+}
+
+func (q *sendQueue) dropOldestAudio() bool {
+	for i, msg := range q.msgs {
+		if msg.audio != nil {
+			q.msgs = append(q.msgs[:i], q.msgs[i+1:]...)
+			q.dropped++
+			return true
+		}
+	}
+
+	return false
+}
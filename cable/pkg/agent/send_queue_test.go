@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendQueue(t *testing.T) {
+	t.Run("audio appends are coalesced", func(t *testing.T) {
+		q := newSendQueue(10, OverflowDropAudio)
+
+		require.NoError(t, q.push(&outboundMsg{audio: []byte{1, 2}}))
+		require.NoError(t, q.push(&outboundMsg{audio: []byte{3}}))
+		require.NoError(t, q.push(&outboundMsg{data: []byte(`{"type":"response.cancel"}`)}))
+		require.NoError(t, q.push(&outboundMsg{audio: []byte{4}}))
+
+		first := q.pop(nil)
+		assert.Equal(t, []byte{1, 2, 3}, first.audio)
+
+		var msg struct {
+			Type  string `json:"type"`
+			Audio []byte `json:"audio"`
+		}
+
+		require.NoError(t, json.Unmarshal(first.encode(), &msg))
+		assert.Equal(t, "input_audio_buffer.append", msg.Type)
+		assert.Equal(t, []byte{1, 2, 3}, msg.Audio)
+
+		assert.Equal(t, `{"type":"response.cancel"}`, string(q.pop(nil).encode()))
+		assert.Equal(t, []byte{4}, q.pop(nil).audio)
+	})
+
+	t.Run("requeued messages go first", func(t *testing.T) {
+		q := newSendQueue(1, OverflowFail)
+
+		require.NoError(t, q.push(&outboundMsg{data: []byte("first")}))
+
+		msg := q.pop(nil)
+
+		require.NoError(t, q.push(&outboundMsg{data: []byte("second")}))
+
+		q.requeue(msg)
+
+		assert.Equal(t, "first", string(q.pop(nil).data))
+		assert.Equal(t, "second", string(q.pop(nil).data))
+	})
+
+	t.Run("coalesced audio is limited", func(t *testing.T) {
+		q := newSendQueue(10, OverflowDropAudio)
+
+		require.NoError(t, q.push(&outboundMsg{audio: make([]byte, maxAudioAppend)}))
+		require.NoError(t, q.push(&outboundMsg{audio: []byte{1}}))
+
+		assert.Len(t, q.pop(nil).audio, maxAudioAppend)
+		assert.Len(t, q.pop(nil).audio, 1)
+	})
+
+	t.Run("the oldest audio is dropped when full", func(t *testing.T) {
+		q := newSendQueue(3, OverflowDropAudio)
+
+		require.NoError(t, q.push(&outboundMsg{data: []byte("a")}))
+		require.NoError(t, q.push(&outboundMsg{audio: []byte{1}}))
+		require.NoError(t, q.push(&outboundMsg{data: []byte("b")}))
+		require.NoError(t, q.push(&outboundMsg{data: []byte("c")}))
+
+		assert.Equal(t, 1, q.droppedAudio())
+
+		assert.Equal(t, "a", string(q.pop(nil).data))
+		assert.Equal(t, "b", string(q.pop(nil).data))
+		assert.Equal(t, "c", string(q.pop(nil).data))
+
+		// Nothing to drop
+		q = newSendQueue(1, OverflowDropAudio)
+
+		require.NoError(t, q.push(&outboundMsg{data: []byte("a")}))
+		assert.ErrorIs(t, q.push(&outboundMsg{data: []byte("b")}), ErrSendQueueFull)
+	})
+
+	t.Run("fail policy", func(t *testing.T) {
+		q := newSendQueue(1, OverflowFail)
+
+		require.NoError(t, q.push(&outboundMsg{audio: []byte{1}}))
+		require.NoError(t, q.push(&outboundMsg{audio: []byte{2}}))
+		assert.ErrorIs(t, q.push(&outboundMsg{data: []byte("a")}), ErrSendQueueFull)
+	})
+
+	t.Run("pop waits for messages", func(t *testing.T) {
+		q := newSendQueue(10, OverflowDropAudio)
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			q.push(&outboundMsg{data: []byte("a")}) // nolint:errcheck
+		}()
+
+		assert.Equal(t, "a", string(q.pop(nil).data))
+
+		done := make(chan struct{})
+		close(done)
+
+		assert.Nil(t, q.pop(done))
+	})
+
+	t.Run("closed queue", func(t *testing.T) {
+		q := newSendQueue(10, OverflowDropAudio)
+
+		require.NoError(t, q.push(&outboundMsg{data: []byte("a")}))
+
+		q.close()
+
+		assert.Nil(t, q.pop(nil))
+		assert.ErrorIs(t, q.push(&outboundMsg{data: []byte("b")}), ErrClosed)
+	})
+}
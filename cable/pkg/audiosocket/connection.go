@@ -0,0 +1,242 @@
+package audiosocket
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const frameDuration = 20 * time.Millisecond
+
+// Connection implements node.Connection for AudioSocket TCP connections.
+//
+// Asterisk expects audio to be sent in real time, so we pace outgoing audio
+// in 20ms frames. Marks are acknowledged when the preceding audio has been sent
+// and clear requests drop the pending audio (just like Twilio does).
+type Connection struct {
+	conn   net.Conn
+	stream *Stream
+
+	inbox chan []byte
+	queue []*Frame
+	// Mark acknowledgements are produced under the lock (so we can't block on the inbox),
+	// they're kept aside until read
+	acks     [][]byte
+	acksFlag chan struct{}
+	closed   bool
+	done     chan struct{}
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+}
+
+func NewConnection(conn net.Conn, stream *Stream) *Connection {
+	c := &Connection{
+		conn:     conn,
+		stream:   stream,
+		inbox:    make(chan []byte, 256),
+		acksFlag: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	go c.readFrames()
+	go c.playFrames()
+
+	return c
+}
+
+// Read returns the next incoming frame (either received from Asterisk or an internal one)
+func (c *Connection) Read() ([]byte, error) {
+	for {
+		if ack := c.nextAck(); ack != nil {
+			return ack, nil
+		}
+
+		select {
+		case msg := <-c.inbox:
+			return msg, nil
+		case <-c.acksFlag:
+		case <-c.done:
+			// Drain frames received before closing (e.g., hangup)
+			if ack := c.nextAck(); ack != nil {
+				return ack, nil
+			}
+
+			select {
+			case msg := <-c.inbox:
+				return msg, nil
+			default:
+			}
+
+			return nil, &websocket.CloseError{Code: websocket.CloseNormalClosure, Text: "connection closed"}
+		}
+	}
+}
+
+func (c *Connection) Write(msg []byte, deadline time.Time) error {
+	return c.WriteBinary(msg, deadline)
+}
+
+func (c *Connection) WriteBinary(msg []byte, deadline time.Time) error {
+	frame, err := ParseFrame(msg)
+
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errors.New("connection closed")
+	}
+
+	switch {
+	case IsAudio(frame.Kind), frame.Kind == kindMark:
+		c.queue = append(c.queue, frame)
+		return nil
+	case frame.Kind == kindClear:
+		for _, pending := range c.queue {
+			if pending.Kind == kindMark {
+				c.ack(pending)
+			}
+		}
+
+		c.queue = nil
+		return nil
+	}
+
+	return c.writeFrame(frame, deadline)
+}
+
+// Close sends a hangup frame and closes the connection
+func (c *Connection) Close(_code int, _reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+
+	c.closed = true
+	c.queue = nil
+
+	_ = c.writeFrame(&Frame{Kind: KindHangup}, time.Now().Add(time.Second))
+	_ = c.conn.Close()
+	close(c.done)
+}
+
+func (c *Connection) Descriptor() net.Conn {
+	return c.conn
+}
+
+func (c *Connection) readFrames() {
+	for {
+		raw, err := ReadFrame(c.conn)
+
+		if err != nil {
+			c.Close(websocket.CloseNormalClosure, "")
+			return
+		}
+
+		select {
+		case c.inbox <- raw:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Connection) playFrames() {
+	ticker := time.NewTicker(frameDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		kind := c.stream.Kind()
+		frameSize := c.stream.FrameSize()
+
+		c.mu.Lock()
+
+		var audio []byte
+
+		for len(c.queue) > 0 && len(audio) < frameSize {
+			next := c.queue[0]
+
+			if next.Kind == kindMark {
+				c.ack(next)
+				c.queue = c.queue[1:]
+				continue
+			}
+
+			n := min(frameSize-len(audio), len(next.Payload))
+			audio = append(audio, next.Payload[:n]...)
+			next.Payload = next.Payload[n:]
+
+			if len(next.Payload) == 0 {
+				c.queue = c.queue[1:]
+			}
+		}
+
+		// Acknowledge marks right after the audio has been sent
+		for len(c.queue) > 0 && c.queue[0].Kind == kindMark {
+			c.ack(c.queue[0])
+			c.queue = c.queue[1:]
+		}
+
+		closed := c.closed
+
+		c.mu.Unlock()
+
+		if len(audio) == 0 || closed {
+			continue
+		}
+
+		if err := c.writeFrame(&Frame{Kind: kind, Payload: audio}, time.Now().Add(time.Second)); err != nil {
+			c.Close(websocket.CloseAbnormalClosure, "")
+			return
+		}
+	}
+}
+
+// ack must be called under the lock
+func (c *Connection) ack(mark *Frame) {
+	c.acks = append(c.acks, mark.Bytes())
+
+	select {
+	case c.acksFlag <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Connection) nextAck() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.acks) == 0 {
+		return nil
+	}
+
+	ack := c.acks[0]
+	c.acks = c.acks[1:]
+
+	return ack
+}
+
+func (c *Connection) writeFrame(frame *Frame, deadline time.Time) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_ = c.conn.SetWriteDeadline(deadline)
+	_, err := c.conn.Write(frame.Bytes())
+
+	return err
+}
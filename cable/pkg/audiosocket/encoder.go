@@ -0,0 +1,129 @@
+package audiosocket
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/encoders"
+	"github.com/anycable/anycable-go/ws"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+// Encoder converts AudioSocket frames from/to the Twilio Media Streams commands,
+// so we can reuse the Twilio executor.
+// It's stateful: audio is sent back in the format of the received one.
+type Encoder struct {
+	stream *Stream
+	log    *slog.Logger
+}
+
+var _ encoders.Encoder = (*Encoder)(nil)
+
+const audioSocketEncoderID = "audiosocket"
+
+func NewEncoder(stream *Stream, l *slog.Logger) *Encoder {
+	return &Encoder{stream: stream, log: l}
+}
+
+func (*Encoder) ID() string {
+	return audioSocketEncoderID
+}
+
+func (enc *Encoder) Encode(msg encoders.EncodedMessage) (*ws.SentFrame, error) {
+	r, ok := msg.(*common.Reply)
+
+	// Ignore pings, disconnects, etc.
+	if !ok {
+		return nil, nil
+	}
+
+	var frame *Frame
+
+	switch r.Type {
+	case twilio.MediaEvent:
+		media, ok := r.Message.(twilio.MediaPayload)
+
+		if !ok {
+			return nil, fmt.Errorf("malformed media message: %v", r.Message)
+		}
+
+		ulaw, err := base64.StdEncoding.DecodeString(media.Payload)
+
+		if err != nil {
+			return nil, err
+		}
+
+		frame = &Frame{Kind: enc.stream.Kind(), Payload: enc.stream.FromUlaw(ulaw)}
+	case twilio.MarkEvent:
+		mark, ok := r.Message.(twilio.MarkPayload)
+
+		if !ok {
+			return nil, fmt.Errorf("malformed mark message: %v", r.Message)
+		}
+
+		frame = &Frame{Kind: kindMark, Payload: []byte(mark.Name)}
+	case twilio.ClearEvent:
+		frame = &Frame{Kind: kindClear}
+	default:
+		// Transmissions, confirmations, etc. are not supported
+		return nil, nil
+	}
+
+	return &ws.SentFrame{FrameType: ws.BinaryFrame, Payload: frame.Bytes()}, nil
+}
+
+func (*Encoder) EncodeTransmission(raw string) (*ws.SentFrame, error) {
+	return nil, nil
+}
+
+func (enc *Encoder) Decode(raw []byte) (*common.Message, error) {
+	frame, err := ParseFrame(raw)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &common.Message{}
+
+	switch frame.Kind {
+	case KindUUID:
+		uuid := FormatUUID(frame.Payload)
+
+		msg.Command = twilio.StartEvent
+		msg.Identifier = uuid
+		msg.Data = twilio.StartPayload{CallSID: uuid, StreamSID: uuid}
+	case KindSlin, KindSlin12, KindSlin16, KindSlin24, KindSlin32, KindSlin44, KindSlin48, KindSlin96, KindSlin192:
+		if frame.Kind != enc.stream.Kind() {
+			enc.stream.SetKind(frame.Kind)
+		}
+
+		msg.Command = twilio.MediaEvent
+		msg.Data = twilio.MediaPayload{Track: "inbound", Payload: base64.StdEncoding.EncodeToString(enc.stream.ToUlaw(frame.Payload))}
+	case KindDTMF:
+		msg.Command = twilio.DTMFEvent
+		msg.Data = twilio.DTMFPayload{Track: "inbound_track", Digit: string(frame.Payload)}
+	case kindMark:
+		msg.Command = twilio.MarkEvent
+		msg.Data = twilio.MarkPayload{Name: string(frame.Payload)}
+	case KindHangup:
+		msg.Command = twilio.StopEvent
+	case KindError:
+		// Asterisk sends errors on hangup or failure, we can only terminate the call
+		msg.Command = twilio.StopEvent
+	default:
+		// We can't serve the call if we don't understand its audio (the error closes the session)
+		if IsAudio(frame.Kind) {
+			err := fmt.Errorf("unsupported audio format: 0x%02x", frame.Kind)
+			enc.log.Error("rejecting call", "error", err)
+			return nil, err
+		}
+
+		// Ignore unknown frames
+		return nil, nil
+	}
+
+	return msg, nil
+}
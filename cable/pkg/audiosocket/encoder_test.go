@@ -0,0 +1,230 @@
+package audiosocket
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+func TestEncoderDecode(t *testing.T) {
+	coder := NewEncoder(NewStream(), slog.Default())
+
+	t.Run("uuid", func(t *testing.T) {
+		uuid := []byte{0x40, 0x32, 0x5e, 0xc2, 0x56, 0x7e, 0x4a, 0x1c, 0x8f, 0x9b, 0x0e, 0x7a, 0x6d, 0x2f, 0x1e, 0x33}
+		frame := &Frame{Kind: KindUUID, Payload: uuid}
+
+		msg, err := coder.Decode(frame.Bytes())
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.StartEvent, msg.Command)
+		assert.Equal(t, "40325ec2-567e-4a1c-8f9b-0e7a6d2f1e33", msg.Identifier)
+		assert.Equal(t, "40325ec2-567e-4a1c-8f9b-0e7a6d2f1e33", msg.Data.(twilio.StartPayload).CallSID)
+	})
+
+	t.Run("audio", func(t *testing.T) {
+		frame := &Frame{Kind: KindSlin, Payload: make([]byte, 320)}
+
+		msg, err := coder.Decode(frame.Bytes())
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.MediaEvent, msg.Command)
+
+		ulaw, err := base64.StdEncoding.DecodeString(msg.Data.(twilio.MediaPayload).Payload)
+		require.NoError(t, err)
+		assert.Len(t, ulaw, 160)
+	})
+
+	t.Run("16kHz audio", func(t *testing.T) {
+		stream := NewStream()
+		coder := NewEncoder(stream, slog.Default())
+
+		frame := &Frame{Kind: KindSlin16, Payload: make([]byte, 640)}
+
+		msg, err := coder.Decode(frame.Bytes())
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.MediaEvent, msg.Command)
+
+		ulaw, err := base64.StdEncoding.DecodeString(msg.Data.(twilio.MediaPayload).Payload)
+		require.NoError(t, err)
+		assert.Len(t, ulaw, 160)
+
+		// Audio is sent back in the same format
+		assert.Equal(t, 16000, stream.SampleRate())
+
+		actual, err := coder.Encode(&common.Reply{Type: twilio.MediaEvent, Message: twilio.MediaPayload{Payload: base64.StdEncoding.EncodeToString(make([]byte, 160))}})
+		require.NoError(t, err)
+
+		reply, err := ParseFrame(actual.Payload)
+		require.NoError(t, err)
+
+		assert.Equal(t, byte(KindSlin16), reply.Kind)
+		assert.Len(t, reply.Payload, 640)
+	})
+
+	t.Run("unsupported audio", func(t *testing.T) {
+		frame := &Frame{Kind: 0x1a, Payload: make([]byte, 320)}
+
+		_, err := coder.Decode(frame.Bytes())
+
+		require.Error(t, err)
+	})
+
+	t.Run("dtmf", func(t *testing.T) {
+		frame := &Frame{Kind: KindDTMF, Payload: []byte("#")}
+
+		msg, err := coder.Decode(frame.Bytes())
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.DTMFEvent, msg.Command)
+		assert.Equal(t, "#", msg.Data.(twilio.DTMFPayload).Digit)
+	})
+
+	t.Run("hangup", func(t *testing.T) {
+		frame := &Frame{Kind: KindHangup}
+
+		msg, err := coder.Decode(frame.Bytes())
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.StopEvent, msg.Command)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := coder.Decode([]byte{KindSlin, 0x01, 0x40, 0x00})
+
+		require.Error(t, err)
+	})
+}
+
+func TestEncoderEncode(t *testing.T) {
+	coder := NewEncoder(NewStream(), slog.Default())
+
+	t.Run("media", func(t *testing.T) {
+		msg := &common.Reply{Type: twilio.MediaEvent, Message: twilio.MediaPayload{Payload: base64.StdEncoding.EncodeToString(make([]byte, 160))}}
+
+		actual, err := coder.Encode(msg)
+		require.NoError(t, err)
+
+		frame, err := ParseFrame(actual.Payload)
+		require.NoError(t, err)
+
+		assert.Equal(t, byte(KindSlin), frame.Kind)
+		assert.Len(t, frame.Payload, 320)
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		actual, err := coder.Encode(&common.PingMessage{Type: "ping"})
+
+		require.NoError(t, err)
+		assert.Nil(t, actual)
+	})
+}
+
+func TestConnectionPlayback(t *testing.T) {
+	server, client := net.Pipe()
+
+	stream := NewStream()
+	conn := NewConnection(server, stream)
+	defer conn.Close(0, "")
+	defer client.Close()
+
+	coder := NewEncoder(stream, slog.Default())
+
+	send := func(msg *common.Reply) {
+		frame, err := coder.Encode(msg)
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteBinary(frame.Payload, time.Now().Add(time.Second)))
+	}
+
+	// 40ms of audio followed by a mark
+	send(&common.Reply{Type: twilio.MediaEvent, Message: twilio.MediaPayload{Payload: base64.StdEncoding.EncodeToString(make([]byte, 320))}})
+	send(&common.Reply{Type: twilio.MarkEvent, Message: twilio.MarkPayload{Name: "first"}})
+	send(&common.Reply{Type: twilio.MediaEvent, Message: twilio.MediaPayload{Payload: base64.StdEncoding.EncodeToString(make([]byte, 1600))}})
+	send(&common.Reply{Type: twilio.MarkEvent, Message: twilio.MarkPayload{Name: "second"}})
+
+	// Audio is sent to Asterisk in 20ms frames
+	for i := 0; i < 2; i++ {
+		raw, err := ReadFrame(client)
+		require.NoError(t, err)
+
+		frame, err := ParseFrame(raw)
+		require.NoError(t, err)
+
+		assert.Equal(t, byte(KindSlin), frame.Kind)
+		assert.Len(t, frame.Payload, 320)
+	}
+
+	// Keep reading audio in background
+	go func() {
+		for {
+			if _, err := ReadFrame(client); err != nil {
+				return
+			}
+		}
+	}()
+
+	raw, err := conn.Read()
+	require.NoError(t, err)
+
+	msg, err := coder.Decode(raw)
+	require.NoError(t, err)
+
+	assert.Equal(t, twilio.MarkEvent, msg.Command)
+	assert.Equal(t, "first", msg.Data.(twilio.MarkPayload).Name)
+
+	// Clear drops the audio and acknowledges the pending marks
+	send(&common.Reply{Type: twilio.ClearEvent})
+
+	raw, err = conn.Read()
+	require.NoError(t, err)
+
+	msg, err = coder.Decode(raw)
+	require.NoError(t, err)
+
+	assert.Equal(t, "second", msg.Data.(twilio.MarkPayload).Name)
+}
+
+func TestConnectionAcksAreNotDropped(t *testing.T) {
+	server, client := net.Pipe()
+
+	stream := NewStream()
+	conn := NewConnection(server, stream)
+	defer conn.Close(0, "")
+	defer client.Close()
+
+	coder := NewEncoder(stream, slog.Default())
+
+	send := func(msg *common.Reply) {
+		frame, err := coder.Encode(msg)
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteBinary(frame.Payload, time.Now().Add(time.Second)))
+	}
+
+	send(&common.Reply{Type: twilio.MediaEvent, Message: twilio.MediaPayload{Payload: base64.StdEncoding.EncodeToString(make([]byte, 1600))}})
+
+	// More marks than the inbox could hold
+	for i := 0; i < 300; i++ {
+		send(&common.Reply{Type: twilio.MarkEvent, Message: twilio.MarkPayload{Name: fmt.Sprintf("mark_%d", i)}})
+	}
+
+	send(&common.Reply{Type: twilio.ClearEvent})
+
+	for i := 0; i < 300; i++ {
+		raw, err := conn.Read()
+		require.NoError(t, err)
+
+		msg, err := coder.Decode(raw)
+		require.NoError(t, err)
+
+		assert.Equal(t, fmt.Sprintf("mark_%d", i), msg.Data.(twilio.MarkPayload).Name)
+	}
+}
@@ -0,0 +1,54 @@
+package audiosocket
+
+import (
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/ws"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+// Executor drives AudioSocket calls through the Twilio executor
+// (the encoder translates frames into Twilio Media Streams commands)
+type Executor struct {
+	twilio *twilio.Executor
+	// Verifies calls before starting them (optional)
+	verifier Verifier
+}
+
+var _ twilio.CommandExecutor = (*Executor)(nil)
+
+func NewExecutor(node node.AppNode, c *twilio.Config, v Verifier, opts ...twilio.ExecutorOption) *Executor {
+	// AudioSocket has no account information, so we must skip account verification
+	conf := *c
+	conf.AccountSID = ""
+
+	return &Executor{twilio: twilio.NewExecutor(node, &conf, opts...), verifier: v}
+}
+
+func (ex *Executor) HandleCommand(s *node.Session, msg *common.Message) error {
+	// There is no "connected" message in AudioSocket, the UUID frame is the first one
+	if msg.Command == twilio.StartEvent && ex.verifier != nil {
+		if err := ex.verifier.Verify(msg.Identifier); err != nil {
+			s.Log.Debug("unverified call", "uuid", msg.Identifier, "error", err)
+			s.Disconnect("Auth Failed", ws.CloseNormalClosure)
+			return nil
+		}
+	}
+
+	if msg.Command == twilio.StartEvent && !s.Connected {
+		if err := ex.twilio.HandleCommand(s, &common.Message{Command: twilio.ConnectedEvent}); err != nil {
+			return err
+		}
+	}
+
+	return ex.twilio.HandleCommand(s, msg)
+}
+
+func (ex *Executor) HandleAppCommand(s *node.Session, cmd *twilio.AppResponse) {
+	ex.twilio.HandleAppCommand(s, cmd)
+}
+
+func (ex *Executor) Disconnect(s *node.Session) error {
+	return ex.twilio.Disconnect(s)
+}
@@ -0,0 +1,118 @@
+package audiosocket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// See https://docs.asterisk.org/Configuration/Channel-Drivers/AudioSocket/
+const (
+	KindHangup = 0x00
+	KindUUID   = 0x01
+	KindDTMF   = 0x03
+	// 8kHz 16-bit signed linear audio
+	KindSlin = 0x10
+	// Signed linear audio of higher sample rates (Asterisk 22+ sends audio in the channel's native format)
+	KindSlin12  = 0x11
+	KindSlin16  = 0x12
+	KindSlin24  = 0x13
+	KindSlin32  = 0x14
+	KindSlin44  = 0x15
+	KindSlin48  = 0x16
+	KindSlin96  = 0x17
+	KindSlin192 = 0x18
+	KindError   = 0xff
+
+	// Internal frames (never sent over the wire), used to emulate
+	// Twilio playback marks and clearing
+	kindMark  = 0xf0
+	kindClear = 0xf1
+
+	headerSize = 3
+)
+
+var slinRates = map[byte]int{
+	KindSlin:    8000,
+	KindSlin12:  12000,
+	KindSlin16:  16000,
+	KindSlin24:  24000,
+	KindSlin32:  32000,
+	KindSlin44:  44100,
+	KindSlin48:  48000,
+	KindSlin96:  96000,
+	KindSlin192: 192000,
+}
+
+// IsAudio returns true if the frame kind is one of the audio kinds (known or not)
+func IsAudio(kind byte) bool {
+	return kind >= KindSlin && kind <= 0x1f
+}
+
+// SampleRate returns the sample rate of the audio frame kind (false if the kind is not supported)
+func SampleRate(kind byte) (int, bool) {
+	rate, ok := slinRates[kind]
+	return rate, ok
+}
+
+var ErrFrameTooLarge = errors.New("frame is too large")
+
+// Frame is a single AudioSocket message
+type Frame struct {
+	Kind    byte
+	Payload []byte
+}
+
+// Bytes returns the wire representation of the frame
+func (f *Frame) Bytes() []byte {
+	buf := make([]byte, headerSize+len(f.Payload))
+	buf[0] = f.Kind
+	binary.BigEndian.PutUint16(buf[1:3], uint16(len(f.Payload))) // #nosec G115
+	copy(buf[headerSize:], f.Payload)
+
+	return buf
+}
+
+// ParseFrame parses the wire representation of the frame
+func ParseFrame(raw []byte) (*Frame, error) {
+	if len(raw) < headerSize {
+		return nil, errors.New("frame is too short")
+	}
+
+	size := int(binary.BigEndian.Uint16(raw[1:3]))
+
+	if len(raw) < headerSize+size {
+		return nil, fmt.Errorf("malformed frame: expected %d bytes payload, got %d", size, len(raw)-headerSize)
+	}
+
+	return &Frame{Kind: raw[0], Payload: raw[headerSize : headerSize+size]}, nil
+}
+
+// ReadFrame reads the next frame from the reader and returns its wire representation
+func ReadFrame(r io.Reader) ([]byte, error) {
+	header := make([]byte, headerSize)
+
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	size := int(binary.BigEndian.Uint16(header[1:3]))
+	raw := make([]byte, headerSize+size)
+	copy(raw, header)
+
+	if _, err := io.ReadFull(r, raw[headerSize:]); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// FormatUUID formats the 16-byte binary UUID
+func FormatUUID(b []byte) string {
+	if len(b) != 16 {
+		return fmt.Sprintf("%x", b)
+	}
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,131 @@
+package audiosocket
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/node"
+	nanoid "github.com/matoous/go-nanoid"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+// Server accepts Asterisk AudioSocket TCP connections.
+// The protocol has no authentication, so the server must only be reachable from a trusted network
+// or calls must be verified (see Verifier).
+type Server struct {
+	addr     string
+	conf     *twilio.Config
+	verifier Verifier
+
+	listener net.Listener
+	log      *slog.Logger
+
+	mu sync.Mutex
+}
+
+func NewServer(addr string, c *twilio.Config, v Verifier, l *slog.Logger) *Server {
+	return &Server{addr: addr, conf: c, verifier: v, log: l.With("context", "audiosocket")}
+}
+
+// Start starts accepting connections in background
+func (s *Server) Start(n *node.Node, opts ...twilio.ExecutorOption) error {
+	listener, err := net.Listen("tcp", s.addr)
+
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	executor := NewExecutor(n, s.conf, s.verifier, opts...)
+
+	s.log.Info("Handle Asterisk AudioSocket connections at tcp://" + listener.Addr().String())
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					s.log.Error("failed to accept connection", "error", err)
+				}
+
+				return
+			}
+
+			s.handle(n, executor, conn)
+		}
+	}()
+
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return s.addr
+	}
+
+	return s.listener.Addr().String()
+}
+
+// Loopback returns true if the server only accepts local connections
+func (s *Server) Loopback() bool {
+	host, _, err := net.SplitHostPort(s.addr)
+
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+
+	return s.listener.Close()
+}
+
+func (s *Server) handle(n *node.Node, executor *Executor, conn net.Conn) {
+	uid, err := nanoid.Nanoid()
+
+	if err != nil {
+		s.log.Error("failed to generate session ID", "error", err)
+		conn.Close()
+		return
+	}
+
+	stream := NewStream()
+	wrappedConn := NewConnection(conn, stream)
+
+	headers := map[string]string{"REMOTE_ADDR": conn.RemoteAddr().String()}
+
+	session := twilio.NewSession(
+		n, wrappedConn, "tcp://"+conn.LocalAddr().String()+"/audiosocket", &headers, uid,
+		NewEncoder(stream, s.log), executor,
+		node.WithHandshakeMessageDeadline(time.Now().Add(5*time.Second)),
+	)
+
+	_ = session.Serve(func() {})
+}
@@ -0,0 +1,58 @@
+package audiosocket
+
+import (
+	"sync"
+
+	"github.com/palkan/twilio-ai-cable/internal/wav"
+)
+
+// Stream holds the audio format of a single AudioSocket connection.
+// Asterisk sends audio in the channel's format, and we reply in the same one.
+// It's shared by the encoder (which converts audio) and the connection (which splits it into frames).
+type Stream struct {
+	kind byte
+	mu   sync.RWMutex
+}
+
+func NewStream() *Stream {
+	return &Stream{kind: KindSlin}
+}
+
+func (st *Stream) SetKind(kind byte) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.kind = kind
+}
+
+// Kind returns the audio frame kind of the stream
+func (st *Stream) Kind() byte {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	return st.kind
+}
+
+func (st *Stream) SampleRate() int {
+	rate, _ := SampleRate(st.Kind())
+	return rate
+}
+
+// FrameSize returns the number of bytes in a 20ms audio frame
+func (st *Stream) FrameSize() int {
+	return st.SampleRate() / 50 * 2
+}
+
+// ToUlaw converts signed linear audio of the stream to 8kHz μ-law
+func (st *Stream) ToUlaw(slin []byte) []byte {
+	f := wav.PCMFormat(1, uint32(st.SampleRate())) // #nosec G115
+
+	ulaw, _ := wav.ToMulaw(&f, slin)
+
+	return ulaw
+}
+
+// FromUlaw converts 8kHz μ-law audio to signed linear audio of the stream
+func (st *Stream) FromUlaw(ulaw []byte) []byte {
+	return wav.FromMulaw(ulaw, st.SampleRate())
+}
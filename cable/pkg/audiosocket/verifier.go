@@ -0,0 +1,81 @@
+package audiosocket
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/utils"
+	"github.com/joomcode/errorx"
+)
+
+// The app's channel to verify AudioSocket calls (see Twilio::AudioSocketChannel)
+const VerifyChannel = "Twilio::AudioSocketChannel"
+
+const (
+	verifyAction  = "verify"
+	verifiedEvent = "audiosocket.verified"
+	responseState = "anycable_response"
+)
+
+var ErrNotVerified = errors.New("call is not verified")
+
+// Verifier checks whether the AudioSocket call (identified by the UUID) is expected.
+// AudioSocket has no authentication, so any TCP peer could start a call otherwise.
+type Verifier interface {
+	Verify(uuid string) error
+}
+
+// RPCVerifier verifies calls by performing the verify action of the AudioSocket channel:
+// the app must respond with the `audiosocket.verified` event to accept the call
+type RPCVerifier struct {
+	controller node.Controller
+}
+
+var _ Verifier = (*RPCVerifier)(nil)
+
+func NewRPCVerifier() *RPCVerifier {
+	return &RPCVerifier{}
+}
+
+// Bind sets the RPC controller to perform verifications with
+// (the controller is created by the runner, so it's not available at the verifier initialization)
+func (v *RPCVerifier) Bind(c node.Controller) {
+	v.controller = c
+}
+
+func (v *RPCVerifier) Verify(uuid string) error {
+	if v.controller == nil {
+		return errors.New("RPC controller is not bound")
+	}
+
+	identifier := string(utils.ToJSON(map[string]string{"channel": VerifyChannel}))
+	payload := string(utils.ToJSON(map[string]string{"action": verifyAction, "uuid": uuid}))
+
+	env := common.NewSessionEnv("", &map[string]string{})
+
+	res, err := v.controller.Perform("audiosocket-verify", env, "", identifier, payload)
+
+	if err != nil {
+		return errorx.Decorate(err, "failed to perform AudioSocket call verification")
+	}
+
+	if res == nil || res.Status != common.SUCCESS || res.IState[responseState] == "" {
+		return ErrNotVerified
+	}
+
+	var reply struct {
+		Event string `json:"event"`
+	}
+
+	if err := json.Unmarshal([]byte(res.IState[responseState]), &reply); err != nil {
+		return errorx.Decorate(err, "failed to parse AudioSocket call verification response")
+	}
+
+	if reply.Event != verifiedEvent {
+		return ErrNotVerified
+	}
+
+	return nil
+}
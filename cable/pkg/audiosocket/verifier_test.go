@@ -0,0 +1,122 @@
+package audiosocket
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/node_mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+// verifyController accepts the predefined calls
+type verifyController struct {
+	mocks.MockController
+
+	uuids map[string]bool
+	err   error
+}
+
+func (c *verifyController) Perform(sid string, env *common.SessionEnv, ids string, channel string, data string) (*common.CommandResult, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	var identifier map[string]string
+	var payload map[string]string
+
+	if err := json.Unmarshal([]byte(channel), &identifier); err != nil || identifier["channel"] != VerifyChannel {
+		return &common.CommandResult{Status: common.FAILURE}, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &payload); err != nil || payload["action"] != "verify" {
+		return &common.CommandResult{Status: common.FAILURE}, nil
+	}
+
+	res := &common.CommandResult{Status: common.SUCCESS}
+
+	if c.uuids[payload["uuid"]] {
+		res.IState = map[string]string{"anycable_response": `{"event":"audiosocket.verified","data":{}}`}
+	}
+
+	return res, nil
+}
+
+func TestRPCVerifier(t *testing.T) {
+	controller := &verifyController{uuids: map[string]bool{"40325ec2-5efd-4bd3-805f-53576e581d13": true}}
+
+	verifier := NewRPCVerifier()
+
+	t.Run("when not bound", func(t *testing.T) {
+		require.Error(t, verifier.Verify("40325ec2-5efd-4bd3-805f-53576e581d13"))
+	})
+
+	verifier.Bind(controller)
+
+	t.Run("verified", func(t *testing.T) {
+		assert.NoError(t, verifier.Verify("40325ec2-5efd-4bd3-805f-53576e581d13"))
+	})
+
+	t.Run("unknown call", func(t *testing.T) {
+		assert.ErrorIs(t, verifier.Verify("00000000-0000-0000-0000-000000000000"), ErrNotVerified)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		controller.err = errors.New("unavailable")
+		defer func() { controller.err = nil }()
+
+		err := verifier.Verify("40325ec2-5efd-4bd3-805f-53576e581d13")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrNotVerified)
+	})
+}
+
+type rejectingVerifier struct{}
+
+func (rejectingVerifier) Verify(uuid string) error {
+	return ErrNotVerified
+}
+
+func TestExecutorRejectsUnverifiedCalls(t *testing.T) {
+	app := &node_mocks.AppNode{}
+
+	controller := mocks.NewMockController()
+	config := node.NewConfig()
+	n := node.NewNode(&config, node.WithController(&controller), node.WithInstrumenter(metrics.NewMetrics(nil, 10, slog.Default())))
+
+	executor := NewExecutor(app, twilio.NewConfig(), rejectingVerifier{})
+
+	session := node.NewSession(n, mocks.NewMockConnection(), "tcp://localhost:9092/audiosocket", nil, "sess-1", node.WithExecutor(executor))
+	session.Log = slog.With("context", "test")
+
+	uuid := "40325ec2-5efd-4bd3-805f-53576e581d13"
+
+	err := executor.HandleCommand(session, &common.Message{Command: twilio.StartEvent, Identifier: uuid, Data: twilio.StartPayload{CallSID: uuid, StreamSID: uuid}})
+	require.NoError(t, err)
+
+	assert.True(t, session.IsClosed())
+	app.AssertNotCalled(t, "Authenticated")
+}
+
+func TestServerLoopback(t *testing.T) {
+	for addr, loopback := range map[string]bool{
+		"127.0.0.1:9092": true,
+		"[::1]:9092":     true,
+		"localhost:9092": true,
+		"0.0.0.0:9092":   false,
+		":9092":          false,
+		"10.0.0.5:9092":  false,
+	} {
+		srv := NewServer(addr, twilio.NewConfig(), nil, slog.Default())
+
+		assert.Equal(t, loopback, srv.Loopback(), addr)
+	}
+}
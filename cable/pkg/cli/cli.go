@@ -1,9 +1,11 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	acli "github.com/anycable/anycable-go/cli"
@@ -11,14 +13,21 @@ import (
 	"github.com/anycable/anycable-go/logger"
 	"github.com/anycable/anycable-go/metrics"
 	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/rpc"
 	"github.com/anycable/anycable-go/server"
 	"github.com/anycable/anycable-go/ws"
 	"github.com/gorilla/websocket"
+	"github.com/joomcode/errorx"
 
 	"github.com/palkan/twilio-ai-cable/internal/fake_rpc"
+	"github.com/palkan/twilio-ai-cable/pkg/audiosocket"
 	"github.com/palkan/twilio-ai-cable/pkg/config"
+	"github.com/palkan/twilio-ai-cable/pkg/telnyx"
+	"github.com/palkan/twilio-ai-cable/pkg/tenant"
+	"github.com/palkan/twilio-ai-cable/pkg/tts"
 	"github.com/palkan/twilio-ai-cable/pkg/twilio"
 	"github.com/palkan/twilio-ai-cable/pkg/version"
+	"github.com/palkan/twilio-ai-cable/pkg/vonage"
 )
 
 func Run(conf *config.Config, anyconf *aconfig.Config) error {
@@ -42,42 +51,309 @@ func Run(conf *config.Config, anyconf *aconfig.Config) error {
 }
 
 func initAnyCableRunner(appConf *config.Config, anyConf *aconfig.Config, l *slog.Logger) (*acli.Runner, error) {
+	var runner *acli.Runner
+	var synth tts.Synthesizer
+
+	if !twilio.ValidRPCFailurePolicy(appConf.Twilio.RPCFailurePolicy) {
+		return nil, fmt.Errorf("unknown agent RPC failure policy: %s (must be %q or %q)", appConf.Twilio.RPCFailurePolicy, twilio.RPCFailureError, twilio.RPCFailureIgnore)
+	}
+
+	if appConf.TTS.Enabled() {
+		var err error
+
+		if synth, err = tts.New(appConf.TTS, l); err != nil {
+			return nil, err
+		}
+	}
+
+	tenants, rpcTenants, err := initTenants(appConf, l)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkCarriersAuth(appConf, tenants != nil); err != nil {
+		return nil, err
+	}
+
+	if tenants != nil && (appConf.Telnyx || appConf.Vonage || appConf.AudioSocketAddr != "") {
+		l.Warn("Tenants only apply to Twilio streams: Telnyx, Vonage and AudioSocket connections are not resolved to tenants")
+	}
+
+	// Endpoint factories are called when the runner starts, so the runner's metrics are available by then
+	executorOpts := func() []twilio.ExecutorOption {
+		opts := []twilio.ExecutorOption{twilio.WithInstrumenter(runner.Instrumenter()), twilio.WithCallChannel()}
+
+		if synth != nil {
+			opts = append(opts, twilio.WithSynthesizer(synth))
+		}
+
+		return opts
+	}
+
 	opts := []acli.Option{
 		acli.WithName("AnyCable"),
 		acli.WithDefaultSubscriber(),
 		acli.WithDefaultBroker(),
 		acli.WithDefaultBroadcaster(),
-		acli.WithWebSocketEndpoint("/twilio", twilioWebsocketHandler(appConf)),
+		acli.WithWebSocketEndpoint("/twilio", twilioWebsocketHandler(appConf, tenants, executorOpts)),
+	}
+
+	if appConf.Telnyx {
+		opts = append(opts, acli.WithWebSocketEndpoint("/telnyx", telnyxWebsocketHandler(appConf, executorOpts)))
+	}
+
+	if appConf.Vonage {
+		opts = append(opts, acli.WithWebSocketEndpoint("/vonage", vonageWebsocketHandler(appConf, executorOpts)))
+	}
+
+	var verifier *audiosocket.RPCVerifier
+
+	if appConf.AudioSocketAddr != "" {
+		var srv *audiosocket.Server
+
+		if appConf.AudioSocketVerify {
+			verifier = audiosocket.NewRPCVerifier()
+			srv = audiosocket.NewServer(appConf.AudioSocketAddr, appConf.Twilio, verifier, l)
+		} else {
+			srv = audiosocket.NewServer(appConf.AudioSocketAddr, appConf.Twilio, nil, l)
+
+			if !srv.Loopback() {
+				if appConf.Twilio.ValidateSignature || tenants != nil {
+					return nil, errors.New("AudioSocket calls must be verified (--audiosocket_verify) or the server must be bound to a loopback address when Twilio streams are authenticated (signatures or tenants)")
+				}
+
+				l.Warn("AudioSocket connections are not authenticated, make sure the server is only reachable from a trusted network", "addr", appConf.AudioSocketAddr)
+			}
+		}
+
+		opts = append(opts,
+			acli.WithShutdownable(srv),
+			acli.WithWebSocketEndpoint("/audiosocket", audioSocketHandler(srv, executorOpts)),
+		)
 	}
 
+	opts = append(opts, acli.WithController(func(m *metrics.Metrics, c *aconfig.Config, lg *slog.Logger) (node.Controller, error) {
+		controller, err := newController(appConf, m, c, lg)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rpcTenants != nil {
+			rpcTenants.Bind(controller)
+		}
+
+		if verifier != nil {
+			verifier.Bind(controller)
+		}
+
+		return controller, nil
+	}))
+
+	runner, err = acli.NewRunner(anyConf, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	twilio.RegisterMetrics(runner.Instrumenter())
+
+	// Call commands channel is served by the Go server, the app only broadcasts to the call streams
+	if err := runner.Router().Route(twilio.CallChannel, twilio.NewCallController(l)); err != nil {
+		return nil, err
+	}
+
+	return runner, nil
+}
+
+func newController(appConf *config.Config, m *metrics.Metrics, c *aconfig.Config, lg *slog.Logger) (node.Controller, error) {
 	if appConf.FakeRPC {
-		opts = append(opts, acli.WithController(func(m *metrics.Metrics, c *aconfig.Config, lg *slog.Logger) (node.Controller, error) {
-			return fake_rpc.NewController(lg), nil
-		}))
-	} else {
-		opts = append(opts, acli.WithDefaultRPCController())
+		if appConf.FakeRPCScenario != "" {
+			sc, err := fake_rpc.LoadScenario(appConf.FakeRPCScenario)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return fake_rpc.NewControllerWithScenario(sc, lg), nil
+		}
+
+		return fake_rpc.NewController(lg), nil
+	}
+
+	if c.RPC.Implementation == "none" {
+		return node.NewNullController(lg), nil
+	}
+
+	return rpc.NewController(m, &c.RPC, lg)
+}
+
+// checkCarriersAuth makes sure other carriers' endpoints can't be used to bypass Twilio authentication
+func checkCarriersAuth(appConf *config.Config, tenants bool) error {
+	if !appConf.Twilio.ValidateSignature && !tenants {
+		return nil
+	}
+
+	if appConf.Telnyx && appConf.TelnyxToken == "" {
+		return errors.New("telnyx token is required when Twilio streams are authenticated (signatures or tenants)")
+	}
+
+	if appConf.Vonage && appConf.VonageToken == "" {
+		return errors.New("vonage token is required when Twilio streams are authenticated (signatures or tenants)")
+	}
+
+	return nil
+}
+
+// initTenants returns the tenant registry (if configured) and the RPC registry to bind to the controller (if used)
+func initTenants(appConf *config.Config, l *slog.Logger) (tenant.Registry, *tenant.RPCRegistry, error) {
+	switch appConf.Tenants {
+	case "":
+		return nil, nil, nil
+	case "rpc":
+		registry := tenant.NewRPCRegistry(appConf.TenantsCacheTTL, l)
+
+		l.Info("Tenants are fetched via RPC")
+
+		return registry, registry, nil
+	default:
+		registry, err := tenant.LoadFile(appConf.Tenants)
+
+		if err != nil {
+			return nil, nil, errorx.Decorate(err, "failed to load tenants")
+		}
+
+		l.Info(fmt.Sprintf("Loaded %d tenants from %s", registry.Size(), appConf.Tenants))
+
+		return registry, nil, nil
 	}
+}
+
+func twilioWebsocketHandler(config *config.Config, tenants tenant.Registry, executorOpts func() []twilio.ExecutorOption) func(n *node.Node, c *aconfig.Config, lg *slog.Logger) (http.Handler, error) {
+	return func(n *node.Node, c *aconfig.Config, lg *slog.Logger) (http.Handler, error) {
+		headers := c.RPC.ProxyHeaders
+
+		// Tenants' signatures are validated when streams start, so we must pass them to sessions
+		if tenants != nil && config.Twilio.ValidateSignature {
+			headers = append([]string{strings.ToLower(twilio.SignatureHeader), strings.ToLower(twilio.StreamURLHeader)}, headers...)
+		}
+
+		extractor := server.DefaultHeadersExtractor{Headers: headers, Cookies: c.RPC.ProxyCookies}
+
+		opts := executorOpts()
+
+		if tenants != nil {
+			opts = append(opts, twilio.WithTenants(tenants))
+		}
+
+		executor := twilio.NewExecutor(n, config.Twilio, opts...)
+
+		lg.Info(fmt.Sprintf("Handle Twilio Media Streams connections at ws://%s:%d/twilio", c.Server.Host, c.Server.Port))
+
+		handler := ws.WebsocketHandler([]string{}, &extractor, &c.WS, lg, func(wsc *websocket.Conn, info *server.RequestInfo, callback func()) error {
+			wrappedConn := ws.NewConnection(wsc)
+			session := twilio.NewSession(
+				n, wrappedConn, info.URL, info.Headers, info.UID,
+				twilio.Encoder{}, executor,
+				node.WithHandshakeMessageDeadline(time.Now().Add(5*time.Second)),
+			)
+
+			return session.Serve(callback)
+		})
+
+		if !config.Twilio.ValidateSignature {
+			return handler, nil
+		}
+
+		if tenants != nil {
+			lg.Info("Twilio request signature validation is enabled (using tenants' auth tokens)")
+
+			return twilio.NewSignatureValidator("", config.Twilio.PublicURL, lg).Deferred(handler), nil
+		}
+
+		if config.Twilio.AuthToken == "" {
+			return nil, errors.New("twilio auth token is required to validate signatures")
+		}
 
-	return acli.NewRunner(anyConf, opts)
+		lg.Info("Twilio request signature validation is enabled")
+
+		return twilio.NewSignatureValidator(config.Twilio.AuthToken, config.Twilio.PublicURL, lg).Middleware(handler), nil
+	}
 }
 
-func twilioWebsocketHandler(config *config.Config) func(n *node.Node, c *aconfig.Config, lg *slog.Logger) (http.Handler, error) {
+func telnyxWebsocketHandler(config *config.Config, executorOpts func() []twilio.ExecutorOption) func(n *node.Node, c *aconfig.Config, lg *slog.Logger) (http.Handler, error) {
 	return func(n *node.Node, c *aconfig.Config, lg *slog.Logger) (http.Handler, error) {
 		extractor := server.DefaultHeadersExtractor{Headers: c.RPC.ProxyHeaders, Cookies: c.RPC.ProxyCookies}
 
-		executor := twilio.NewExecutor(n, config.Twilio)
+		executor := telnyx.NewExecutor(n, config.Twilio, executorOpts()...)
 
-		lg.Info(fmt.Sprintf("Handle Twilio Media Streams connections at ws://%s:%d/twilio", c.Server.Host, c.Server.Port))
+		lg.Info(fmt.Sprintf("Handle Telnyx media streaming connections at ws://%s:%d/telnyx", c.Server.Host, c.Server.Port))
 
-		return ws.WebsocketHandler([]string{}, &extractor, &c.WS, lg, func(wsc *websocket.Conn, info *server.RequestInfo, callback func()) error {
+		handler := ws.WebsocketHandler([]string{}, &extractor, &c.WS, lg, func(wsc *websocket.Conn, info *server.RequestInfo, callback func()) error {
 			wrappedConn := ws.NewConnection(wsc)
-			session := node.NewSession(
+			session := twilio.NewSession(
+				n, wrappedConn, info.URL, info.Headers, info.UID,
+				telnyx.Encoder{}, executor,
+				node.WithHandshakeMessageDeadline(time.Now().Add(5*time.Second)),
+			)
+
+			return session.Serve(callback)
+		})
+
+		return withToken(handler, config.TelnyxToken, "Telnyx", lg), nil
+	}
+}
+
+func vonageWebsocketHandler(config *config.Config, executorOpts func() []twilio.ExecutorOption) func(n *node.Node, c *aconfig.Config, lg *slog.Logger) (http.Handler, error) {
+	return func(n *node.Node, c *aconfig.Config, lg *slog.Logger) (http.Handler, error) {
+		extractor := server.DefaultHeadersExtractor{Headers: c.RPC.ProxyHeaders, Cookies: c.RPC.ProxyCookies}
+
+		executor := vonage.NewExecutor(n, config.Twilio, executorOpts()...)
+
+		lg.Info(fmt.Sprintf("Handle Vonage WebSocket connections at ws://%s:%d/vonage", c.Server.Host, c.Server.Port))
+
+		handler := ws.WebsocketHandler([]string{}, &extractor, &c.WS, lg, func(wsc *websocket.Conn, info *server.RequestInfo, callback func()) error {
+			// Audio format is negotiated per connection, so both the encoder and the connection are stateful
+			stream := vonage.NewStream()
+			wrappedConn := vonage.NewConnection(wsc, stream)
+			session := twilio.NewSession(
 				n, wrappedConn, info.URL, info.Headers, info.UID,
-				node.WithEncoder(twilio.Encoder{}), node.WithExecutor(executor),
+				vonage.NewEncoder(stream), executor,
 				node.WithHandshakeMessageDeadline(time.Now().Add(5*time.Second)),
 			)
 
 			return session.Serve(callback)
+		})
+
+		return withToken(handler, config.VonageToken, "Vonage", lg), nil
+	}
+}
+
+// withToken requires the carrier's requests to be authenticated with the shared secret (if any)
+func withToken(handler http.Handler, token string, carrier string, lg *slog.Logger) http.Handler {
+	if token == "" {
+		lg.Warn(fmt.Sprintf("%s connections are not authenticated", carrier))
+		return handler
+	}
+
+	lg.Info(fmt.Sprintf("%s connections token authentication is enabled", carrier))
+
+	return twilio.NewTokenValidator(token, lg).Middleware(handler)
+}
+
+// AnyCable runner doesn't provide hooks to run custom servers, so we use an HTTP endpoint factory
+// to start the AudioSocket server (the node is only available there).
+// The endpoint itself could be used as a health check.
+func audioSocketHandler(srv *audiosocket.Server, executorOpts func() []twilio.ExecutorOption) func(n *node.Node, c *aconfig.Config, lg *slog.Logger) (http.Handler, error) {
+	return func(n *node.Node, c *aconfig.Config, lg *slog.Logger) (http.Handler, error) {
+		if err := srv.Start(n, executorOpts()...); err != nil {
+			return nil, err
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, "AudioSocket server is listening at tcp://%s", srv.Addr())
 		}), nil
 	}
 }
@@ -14,6 +14,213 @@ func CustomOptions(conf *config.Config) func() ([]cli.Flag, error) {
 					EnvVars:     []string{"TWILIO_ACCOUNT_SID"},
 					Destination: &conf.Twilio.AccountSID,
 				},
+				&cli.StringFlag{
+					Category:    "TWILIO",
+					Name:        "twilio_auth_token",
+					Usage:       "Twilio account auth token (used to validate request signatures)",
+					EnvVars:     []string{"TWILIO_AUTH_TOKEN"},
+					Destination: &conf.Twilio.AuthToken,
+				},
+				&cli.BoolFlag{
+					Category:    "TWILIO",
+					Name:        "twilio_validate_signature",
+					Usage:       "Reject /twilio connections without a valid X-Twilio-Signature header",
+					EnvVars:     []string{"TWILIO_VALIDATE_SIGNATURE"},
+					Destination: &conf.Twilio.ValidateSignature,
+					Value:       conf.Twilio.ValidateSignature,
+				},
+				&cli.StringFlag{
+					Category:    "TWILIO",
+					Name:        "twilio_public_url",
+					Usage:       "Public base URL of the server as seen by Twilio (e.g., wss://cable.example.com) to validate signatures behind proxies",
+					EnvVars:     []string{"TWILIO_PUBLIC_URL"},
+					Destination: &conf.Twilio.PublicURL,
+				},
+				&cli.StringFlag{
+					Category:    "TENANTS",
+					Name:        "tenants",
+					Usage:       `Resolve tenants by account SIDs: path to the YAML/JSON file with tenants or "rpc" to fetch them from the app`,
+					EnvVars:     []string{"TENANTS"},
+					Destination: &conf.Tenants,
+				},
+				&cli.DurationFlag{
+					Category:    "TENANTS",
+					Name:        "tenants_cache_ttl",
+					Usage:       "How long to cache tenants fetched via RPC",
+					EnvVars:     []string{"TENANTS_CACHE_TTL"},
+					Destination: &conf.TenantsCacheTTL,
+					Value:       conf.TenantsCacheTTL,
+				},
+				&cli.BoolFlag{
+					Category:    "CARRIERS",
+					Name:        "telnyx",
+					Usage:       "Accept Telnyx media streams at /telnyx",
+					EnvVars:     []string{"TELNYX"},
+					Destination: &conf.Telnyx,
+					Value:       conf.Telnyx,
+				},
+				&cli.StringFlag{
+					Category:    "CARRIERS",
+					Name:        "telnyx_token",
+					Usage:       "Shared secret to authenticate Telnyx streams (passed via the token query parameter or the Authorization: Bearer header)",
+					EnvVars:     []string{"TELNYX_TOKEN"},
+					Destination: &conf.TelnyxToken,
+				},
+				&cli.BoolFlag{
+					Category:    "CARRIERS",
+					Name:        "vonage",
+					Usage:       "Accept Vonage WebSocket calls at /vonage",
+					EnvVars:     []string{"VONAGE"},
+					Destination: &conf.Vonage,
+					Value:       conf.Vonage,
+				},
+				&cli.StringFlag{
+					Category:    "CARRIERS",
+					Name:        "vonage_token",
+					Usage:       "Shared secret to authenticate Vonage calls (passed via the token query parameter or the Authorization: Bearer header)",
+					EnvVars:     []string{"VONAGE_TOKEN"},
+					Destination: &conf.VonageToken,
+				},
+				&cli.StringFlag{
+					Category:    "AUDIOSOCKET",
+					Name:        "audiosocket_addr",
+					Usage:       "Accept Asterisk AudioSocket TCP connections at the specified address (e.g., 127.0.0.1:9092); AudioSocket has no authentication, so only bind it to a trusted network or enable --audiosocket_verify",
+					EnvVars:     []string{"AUDIOSOCKET_ADDR"},
+					Destination: &conf.AudioSocketAddr,
+				},
+				&cli.BoolFlag{
+					Category:    "AUDIOSOCKET",
+					Name:        "audiosocket_verify",
+					Usage:       "Verify AudioSocket calls by their UUIDs via the verify action of the Twilio::AudioSocketChannel",
+					EnvVars:     []string{"AUDIOSOCKET_VERIFY"},
+					Destination: &conf.AudioSocketVerify,
+					Value:       conf.AudioSocketVerify,
+				},
+				&cli.BoolFlag{
+					Category:    "RECORDING",
+					Name:        "record",
+					Usage:       "Record calls (could be overridden per call via the configure_openai response)",
+					EnvVars:     []string{"RECORD"},
+					Destination: &conf.Twilio.Record,
+					Value:       conf.Twilio.Record,
+				},
+				&cli.StringFlag{
+					Category:    "RECORDING",
+					Name:        "recordings_dir",
+					Usage:       "Directory to store call recordings",
+					EnvVars:     []string{"RECORDINGS_DIR"},
+					Destination: &conf.Twilio.RecordingsDir,
+					Value:       conf.Twilio.RecordingsDir,
+				},
+				&cli.StringFlag{
+					Category:    "RECORDING",
+					Name:        "recording_mode",
+					Usage:       "Recording mode: mixed (mono) or split (stereo, caller on the left, bot on the right)",
+					EnvVars:     []string{"RECORDING_MODE"},
+					Destination: &conf.Twilio.RecordingMode,
+					Value:       conf.Twilio.RecordingMode,
+				},
+				&cli.DurationFlag{
+					Category:    "LIMITS",
+					Name:        "max_call_duration",
+					Usage:       "Hang up calls lasting longer than the specified duration (e.g., 30m)",
+					EnvVars:     []string{"MAX_CALL_DURATION"},
+					Destination: &conf.Twilio.Limits.MaxDuration,
+				},
+				&cli.DurationFlag{
+					Category:    "LIMITS",
+					Name:        "max_silence",
+					Usage:       "Hang up calls when the caller stays silent longer than the specified duration (e.g., 1m)",
+					EnvVars:     []string{"MAX_SILENCE"},
+					Destination: &conf.Twilio.Limits.MaxSilence,
+				},
+				&cli.DurationFlag{
+					Category:    "LIMITS",
+					Name:        "max_idle",
+					Usage:       "Hang up calls with no activity from both sides longer than the specified duration (e.g., 30s)",
+					EnvVars:     []string{"MAX_IDLE"},
+					Destination: &conf.Twilio.Limits.MaxIdle,
+				},
+				&cli.StringFlag{
+					Category:    "LIMITS",
+					Name:        "timeout_prompt",
+					Usage:       "Instructions for the agent to say before hanging up when a limit is reached (could be overridden per call via the configure_openai response)",
+					EnvVars:     []string{"TIMEOUT_PROMPT"},
+					Destination: &conf.Twilio.TimeoutPrompt,
+				},
+				&cli.DurationFlag{
+					Category:    "LIMITS",
+					Name:        "timeout_prompt_wait",
+					Usage:       "How long to wait for the closing prompt to be played before hanging up",
+					EnvVars:     []string{"TIMEOUT_PROMPT_WAIT"},
+					Destination: &conf.Twilio.TimeoutPromptWait,
+					Value:       conf.Twilio.TimeoutPromptWait,
+				},
+				&cli.IntFlag{
+					Category:    "USAGE",
+					Name:        "usage_report_interval",
+					Usage:       "Report token usage to the app (handle_usage action) every N agent responses (0 means only when the call ends)",
+					EnvVars:     []string{"USAGE_REPORT_INTERVAL"},
+					Destination: &conf.Twilio.UsageReportInterval,
+				},
+				&cli.StringFlag{
+					Category:    "AGENT",
+					Name:        "turn_key",
+					Usage:       "DTMF key to end the caller's turn when turn detection is disabled, e.g., # (could be overridden per call via the configure_openai response)",
+					EnvVars:     []string{"TURN_KEY"},
+					Destination: &conf.Twilio.TurnKey,
+				},
+				&cli.DurationFlag{
+					Category:    "AGENT",
+					Name:        "agent_rpc_timeout",
+					Usage:       "How long to wait for the app to handle the agent's events (transcripts, function calls); 0 means no limit",
+					EnvVars:     []string{"AGENT_RPC_TIMEOUT"},
+					Destination: &conf.Twilio.RPCTimeout,
+					Value:       conf.Twilio.RPCTimeout,
+				},
+				&cli.StringFlag{
+					Category:    "AGENT",
+					Name:        "agent_rpc_failure_policy",
+					Usage:       `What to do when a function call RPC fails or times out: "error" (send an error result to the agent) or "ignore"`,
+					EnvVars:     []string{"AGENT_RPC_FAILURE_POLICY"},
+					Destination: &conf.Twilio.RPCFailurePolicy,
+					Value:       conf.Twilio.RPCFailurePolicy,
+				},
+				&cli.StringFlag{
+					Category:    "TTS",
+					Name:        "tts",
+					Usage:       "Text-to-speech backend to speak the app's phrases in calls without the agent (e.g., openai)",
+					EnvVars:     []string{"TTS"},
+					Destination: &conf.TTS.Provider,
+				},
+				&cli.StringFlag{
+					Category:    "TTS",
+					Name:        "tts_api_key",
+					EnvVars:     []string{"TTS_API_KEY", "OPENAI_API_KEY"},
+					Destination: &conf.TTS.Key,
+				},
+				&cli.StringFlag{
+					Category:    "TTS",
+					Name:        "tts_url",
+					EnvVars:     []string{"TTS_URL"},
+					Destination: &conf.TTS.URL,
+					Value:       conf.TTS.URL,
+				},
+				&cli.StringFlag{
+					Category:    "TTS",
+					Name:        "tts_model",
+					EnvVars:     []string{"TTS_MODEL"},
+					Destination: &conf.TTS.Model,
+					Value:       conf.TTS.Model,
+				},
+				&cli.StringFlag{
+					Category:    "TTS",
+					Name:        "tts_voice",
+					Usage:       "Default voice (could be overridden per phrase)",
+					EnvVars:     []string{"TTS_VOICE"},
+					Destination: &conf.TTS.Voice,
+					Value:       conf.TTS.Voice,
+				},
 				&cli.BoolFlag{
 					Category:    "MISC",
 					Name:        "fake_rpc",
@@ -21,6 +228,13 @@ func CustomOptions(conf *config.Config) func() ([]cli.Flag, error) {
 					Destination: &conf.FakeRPC,
 					Value:       conf.FakeRPC,
 				},
+				&cli.StringFlag{
+					Category:    "MISC",
+					Name:        "fake_rpc_scenario",
+					Usage:       "Path to the YAML/JSON file with canned RPC responses (for --fake_rpc)",
+					EnvVars:     []string{"FAKE_RPC_SCENARIO"},
+					Destination: &conf.FakeRPCScenario,
+				},
 			},
 			nil
 	}
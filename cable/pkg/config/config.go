@@ -1,15 +1,43 @@
 package config
 
-import "github.com/palkan/twilio-ai-cable/pkg/twilio"
+import (
+	"time"
+
+	"github.com/palkan/twilio-ai-cable/pkg/tts"
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
 
 type Config struct {
 	FakeRPC bool
-	Twilio  *twilio.Config
+	// Path to the YAML/JSON scenario for the fake RPC controller
+	FakeRPCScenario string
+	// Address to accept Asterisk AudioSocket connections at (disabled if empty)
+	AudioSocketAddr string
+	// Verify AudioSocket calls via the app before starting them (see audiosocket.RPCVerifier)
+	AudioSocketVerify bool
+	// Accept Telnyx media streams at /telnyx
+	Telnyx bool
+	// Shared secret Telnyx streams must be authenticated with (see twilio.TokenValidator)
+	TelnyxToken string
+	// Accept Vonage WebSocket calls at /vonage
+	Vonage bool
+	// Shared secret Vonage calls must be authenticated with (see twilio.TokenValidator)
+	VonageToken string
+	Twilio      *twilio.Config
+	// Text-to-speech backend for calls without the agent
+	TTS *tts.Config
+	// Path to the YAML/JSON file with tenants or "rpc" to fetch them from the app (disabled if empty)
+	Tenants string
+	// How long to cache tenants fetched via RPC
+	TenantsCacheTTL time.Duration
 }
 
 func NewConfig() *Config {
 	return &Config{
 		FakeRPC: false,
 		Twilio:  twilio.NewConfig(),
+		TTS:     tts.NewConfig(),
+
+		TenantsCacheTTL: time.Minute,
 	}
 }
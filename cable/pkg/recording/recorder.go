@@ -0,0 +1,240 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/palkan/twilio-ai-cable/internal/g711"
+	"github.com/palkan/twilio-ai-cable/internal/wav"
+)
+
+const (
+	// Both tracks are mixed into a single mono channel
+	ModeMixed = "mixed"
+	// Caller is recorded to the left channel, bot — to the right one
+	ModeSplit = "split"
+
+	sampleRate = 8000
+)
+
+// Recorder captures both sides of a call (8kHz μ-law) on a common timeline.
+//
+// Caller's audio is a continuous stream, so we use it as a clock:
+// the bot's audio is placed at the current caller's position (or right after
+// the previous bot's chunk if it's still being played).
+//
+// Samples are streamed to a temporary file as soon as the caller's position passes them
+// (as interleaved caller/bot 16-bit pairs), so only the bot's audio queued ahead of the caller
+// is kept in memory.
+type Recorder struct {
+	file *os.File
+	w    *bufio.Writer
+	// Number of samples written to the file
+	written int
+	// Bot's audio which hasn't been reached by the caller's position yet
+	pending []int16
+	// The first write error (the recording is lost)
+	err error
+
+	mu sync.Mutex
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// WriteInbound adds the caller's audio and moves the timeline forward
+func (r *Recorder) WriteInbound(ulaw []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	if r.file == nil {
+		f, err := os.CreateTemp("", "recording-*.pcm")
+
+		if err != nil {
+			r.err = err
+			return
+		}
+
+		r.file = f
+		r.w = bufio.NewWriter(f)
+	}
+
+	var frame [4]byte
+
+	for i, b := range ulaw {
+		binary.LittleEndian.PutUint16(frame[0:], uint16(g711.DecodeUlawFrame(b)))
+		binary.LittleEndian.PutUint16(frame[2:], uint16(sampleAt(r.pending, i)))
+
+		if _, err := r.w.Write(frame[:]); err != nil {
+			r.err = err
+			return
+		}
+	}
+
+	r.written += len(ulaw)
+	r.pending = r.pending[min(len(ulaw), len(r.pending)):]
+}
+
+// WriteOutbound adds the bot's audio to the playback queue
+func (r *Recorder) WriteOutbound(ulaw []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending = appendDecoded(r.pending, ulaw)
+}
+
+// Clear drops the bot's audio which hasn't been played yet (e.g., when the caller interrupts the bot)
+func (r *Recorder) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending = nil
+}
+
+// Len returns the number of samples recorded
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.written + len(r.pending)
+}
+
+// Save writes the recording to the directory as <name>.wav (16-bit PCM) and returns the file path.
+// The temporary data is removed, so the recorder must not be used after that.
+func (r *Recorder) Save(dir string, name string, mode string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	defer r.cleanup()
+
+	if r.err != nil {
+		return "", r.err
+	}
+
+	var format wav.Format
+
+	switch mode {
+	case ModeSplit:
+		format = wav.PCMFormat(2, sampleRate)
+	case ModeMixed, "":
+		format = wav.PCMFormat(1, sampleRate)
+	default:
+		return "", fmt.Errorf("unknown recording mode: %s", mode)
+	}
+
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, filepath.Base(name)+".wav")
+
+	f, err := os.Create(filepath.Clean(path))
+
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	out := bufio.NewWriter(f)
+	n := r.written + len(r.pending)
+
+	if err := wav.WriteHeader(out, format, n*int(format.Channels)*2); err != nil {
+		return "", err
+	}
+
+	if r.file != nil {
+		if err := r.w.Flush(); err != nil {
+			return "", err
+		}
+
+		if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+
+		in := bufio.NewReader(r.file)
+		var frame [4]byte
+
+		for i := 0; i < r.written; i++ {
+			if _, err := io.ReadFull(in, frame[:]); err != nil {
+				return "", err
+			}
+
+			inbound := int16(binary.LittleEndian.Uint16(frame[0:]))
+			outbound := int16(binary.LittleEndian.Uint16(frame[2:]))
+
+			if err := writeSample(out, mode, inbound, outbound); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	// The rest of the bot's audio (the caller hung up while it was being played)
+	for _, outbound := range r.pending {
+		if err := writeSample(out, mode, 0, outbound); err != nil {
+			return "", err
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+func (r *Recorder) cleanup() {
+	if r.file != nil {
+		r.file.Close()
+		os.Remove(r.file.Name())
+		r.file = nil
+	}
+
+	r.pending = nil
+}
+
+func writeSample(w io.Writer, mode string, inbound int16, outbound int16) error {
+	var frame [4]byte
+
+	if mode == ModeSplit {
+		binary.LittleEndian.PutUint16(frame[0:], uint16(inbound))
+		binary.LittleEndian.PutUint16(frame[2:], uint16(outbound))
+
+		_, err := w.Write(frame[:])
+		return err
+	}
+
+	mixed := int32(inbound) + int32(outbound)
+	mixed = min(max(mixed, -32768), 32767)
+
+	binary.LittleEndian.PutUint16(frame[0:], uint16(int16(mixed)))
+
+	_, err := w.Write(frame[:2])
+	return err
+}
+
+func appendDecoded(samples []int16, ulaw []byte) []int16 {
+	for _, b := range ulaw {
+		samples = append(samples, g711.DecodeUlawFrame(b))
+	}
+
+	return samples
+}
+
+func sampleAt(samples []int16, i int) int16 {
+	if i < len(samples) {
+		return samples[i]
+	}
+
+	return 0
+}
@@ -0,0 +1,137 @@
+package recording
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palkan/twilio-ai-cable/internal/g711"
+	"github.com/palkan/twilio-ai-cable/internal/wav"
+)
+
+func TestRecorder(t *testing.T) {
+	loud := g711.EncodeUlawFrame(1000)
+	quiet := g711.EncodeUlawFrame(-500)
+
+	frame := func(b byte, n int) []byte {
+		res := make([]byte, n)
+		for i := range res {
+			res[i] = b
+		}
+		return res
+	}
+
+	t.Run("aligns bot audio on the caller's timeline", func(t *testing.T) {
+		rec := NewRecorder()
+
+		rec.WriteInbound(frame(loud, 160))
+		rec.WriteOutbound(frame(quiet, 80))
+		// Bot's audio is queued after the previous chunk
+		rec.WriteOutbound(frame(quiet, 80))
+		rec.WriteInbound(frame(loud, 160))
+
+		dir := t.TempDir()
+		path, err := rec.Save(dir, "CA42", ModeSplit)
+		require.NoError(t, err)
+
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+
+		format, data, err := wav.Read(f)
+		require.NoError(t, err)
+
+		assert.Equal(t, wav.PCMFormat(2, 8000), *format)
+		// 320 samples * 2 channels * 2 bytes
+		require.Len(t, data, 1280)
+
+		sample := func(i int, ch int) int16 {
+			return int16(uint16(data[i*4+ch*2]) | uint16(data[i*4+ch*2+1])<<8)
+		}
+
+		assert.Equal(t, g711.DecodeUlawFrame(loud), sample(0, 0))
+		assert.Equal(t, int16(0), sample(0, 1))
+		assert.Equal(t, g711.DecodeUlawFrame(quiet), sample(160, 1))
+		assert.Equal(t, g711.DecodeUlawFrame(quiet), sample(319, 1))
+	})
+
+	t.Run("clear drops the unplayed audio", func(t *testing.T) {
+		rec := NewRecorder()
+
+		rec.WriteInbound(frame(loud, 160))
+		rec.WriteOutbound(frame(quiet, 800))
+
+		assert.Equal(t, 960, rec.Len())
+
+		rec.Clear()
+
+		assert.Equal(t, 160, rec.Len())
+	})
+
+	t.Run("mixed mode", func(t *testing.T) {
+		rec := NewRecorder()
+
+		rec.WriteOutbound(frame(quiet, 160))
+		rec.WriteInbound(frame(loud, 160))
+
+		path, err := rec.Save(t.TempDir(), "../CA42", ModeMixed)
+		require.NoError(t, err)
+
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+
+		format, data, err := wav.Read(f)
+		require.NoError(t, err)
+
+		assert.Equal(t, wav.PCMFormat(1, 8000), *format)
+		require.Len(t, data, 320)
+
+		mixed := int16(uint16(data[0]) | uint16(data[1])<<8)
+		assert.Equal(t, g711.DecodeUlawFrame(loud)+g711.DecodeUlawFrame(quiet), mixed)
+	})
+	t.Run("keeps only the unplayed audio in memory", func(t *testing.T) {
+		rec := NewRecorder()
+
+		rec.WriteOutbound(frame(quiet, 240))
+
+		for i := 0; i < 3; i++ {
+			rec.WriteInbound(frame(loud, 160))
+		}
+
+		assert.Empty(t, rec.pending)
+		assert.Equal(t, 480, rec.Len())
+
+		// The caller hangs up while the bot is speaking
+		rec.WriteOutbound(frame(quiet, 80))
+
+		tmp := rec.file.Name()
+
+		path, err := rec.Save(t.TempDir(), "CA42", ModeSplit)
+		require.NoError(t, err)
+
+		_, err = os.Stat(tmp)
+		assert.True(t, os.IsNotExist(err))
+
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+
+		_, data, err := wav.Read(f)
+		require.NoError(t, err)
+
+		// 560 samples * 2 channels * 2 bytes
+		require.Len(t, data, 2240)
+
+		sample := func(i int, ch int) int16 {
+			return int16(uint16(data[i*4+ch*2]) | uint16(data[i*4+ch*2+1])<<8)
+		}
+
+		assert.Equal(t, g711.DecodeUlawFrame(quiet), sample(239, 1))
+		assert.Equal(t, int16(0), sample(240, 1))
+		assert.Equal(t, int16(0), sample(500, 0))
+		assert.Equal(t, g711.DecodeUlawFrame(quiet), sample(500, 1))
+	})
+}
@@ -0,0 +1,122 @@
+package telnyx
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/encoders"
+	"github.com/anycable/anycable-go/ws"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+// Encoder converts Telnyx media streaming messages from/to Twilio Media Streams commands,
+// so we can reuse the Twilio executor.
+type Encoder struct {
+}
+
+var _ encoders.Encoder = (*Encoder)(nil)
+
+const telnyxEncoderID = "telnyx"
+
+func (Encoder) ID() string {
+	return telnyxEncoderID
+}
+
+func (Encoder) Encode(msg encoders.EncodedMessage) (*ws.SentFrame, error) {
+	r, ok := msg.(*common.Reply)
+
+	// Ignore pings, disconnects, etc.
+	if !ok {
+		return nil, nil
+	}
+
+	var response *Message
+
+	switch r.Type {
+	case twilio.MediaEvent:
+		media, ok := r.Message.(twilio.MediaPayload)
+
+		if !ok {
+			return nil, fmt.Errorf("malformed media message: %v", r.Message)
+		}
+
+		response = &Message{Event: MediaEvent, Media: &MediaPayload{Payload: media.Payload}}
+	case twilio.MarkEvent:
+		mark, ok := r.Message.(twilio.MarkPayload)
+
+		if !ok {
+			return nil, fmt.Errorf("malformed mark message: %v", r.Message)
+		}
+
+		response = &Message{Event: MarkEvent, Mark: &MarkPayload{Name: mark.Name}}
+	case twilio.ClearEvent:
+		response = &Message{Event: ClearEvent}
+	default:
+		// Transmissions, confirmations, etc. are not supported
+		return nil, nil
+	}
+
+	b, err := json.Marshal(response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ws.SentFrame{FrameType: ws.TextFrame, Payload: b}, nil
+}
+
+func (Encoder) EncodeTransmission(raw string) (*ws.SentFrame, error) {
+	return nil, nil
+}
+
+func (Encoder) Decode(raw []byte) (*common.Message, error) {
+	var tMsg Message
+
+	if err := json.Unmarshal(raw, &tMsg); err != nil {
+		return nil, err
+	}
+
+	msg := &common.Message{Command: tMsg.Event, Identifier: tMsg.StreamID}
+
+	switch tMsg.Event {
+	case ConnectedEvent:
+		msg.Command = twilio.ConnectedEvent
+	case StartEvent:
+		if tMsg.Start == nil {
+			return nil, fmt.Errorf("malformed start message: %s", raw)
+		}
+
+		msg.Command = twilio.StartEvent
+		msg.Data = *tMsg.Start
+	case MediaEvent:
+		if tMsg.Media == nil {
+			return nil, fmt.Errorf("malformed media message: %s", raw)
+		}
+
+		msg.Command = twilio.MediaEvent
+		msg.Data = twilio.MediaPayload{Track: tMsg.Media.Track, Payload: tMsg.Media.Payload}
+	case MarkEvent:
+		if tMsg.Mark == nil {
+			return nil, fmt.Errorf("malformed mark message: %s", raw)
+		}
+
+		msg.Command = twilio.MarkEvent
+		msg.Data = twilio.MarkPayload{Name: tMsg.Mark.Name}
+	case DTMFEvent:
+		if tMsg.DTMF == nil {
+			return nil, fmt.Errorf("malformed dtmf message: %s", raw)
+		}
+
+		msg.Command = twilio.DTMFEvent
+		msg.Data = twilio.DTMFPayload{Digit: tMsg.DTMF.Digit}
+	case StopEvent:
+		msg.Command = twilio.StopEvent
+	default:
+		// Ignore unknown events (e.g., errors)
+		return nil, nil
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,93 @@
+package telnyx
+
+import (
+	"testing"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+func TestEncoderDecode(t *testing.T) {
+	coder := Encoder{}
+
+	t.Run("start", func(t *testing.T) {
+		raw := `{"event":"start","sequence_number":"1","stream_id":"32de0dea-53cb","start":{"call_control_id":"v3:abc","media_format":{"encoding":"PCMU","sample_rate":8000,"channels":1}}}`
+
+		msg, err := coder.Decode([]byte(raw))
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.StartEvent, msg.Command)
+		assert.Equal(t, "32de0dea-53cb", msg.Identifier)
+
+		start := msg.Data.(StartPayload)
+		assert.Equal(t, "v3:abc", start.CallControlID)
+		assert.Equal(t, "PCMU", start.MediaFormat.Encoding)
+	})
+
+	t.Run("media", func(t *testing.T) {
+		raw := `{"event":"media","stream_id":"32de0dea-53cb","media":{"track":"inbound","chunk":"2","timestamp":"5","payload":"AAAA"}}`
+
+		msg, err := coder.Decode([]byte(raw))
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.MediaEvent, msg.Command)
+		assert.Equal(t, twilio.MediaPayload{Track: "inbound", Payload: "AAAA"}, msg.Data)
+	})
+
+	t.Run("dtmf", func(t *testing.T) {
+		msg, err := coder.Decode([]byte(`{"event":"dtmf","stream_id":"32de0dea-53cb","dtmf":{"digit":"1"}}`))
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.DTMFEvent, msg.Command)
+		assert.Equal(t, "1", msg.Data.(twilio.DTMFPayload).Digit)
+	})
+
+	t.Run("mark", func(t *testing.T) {
+		msg, err := coder.Decode([]byte(`{"event":"mark","stream_id":"32de0dea-53cb","mark":{"name":"ai-delta-1"}}`))
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.MarkEvent, msg.Command)
+		assert.Equal(t, "ai-delta-1", msg.Data.(twilio.MarkPayload).Name)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		msg, err := coder.Decode([]byte(`{"event":"error","payload":{"code":100002}}`))
+
+		require.NoError(t, err)
+		assert.Nil(t, msg)
+	})
+}
+
+func TestEncoderEncode(t *testing.T) {
+	coder := Encoder{}
+
+	t.Run("media", func(t *testing.T) {
+		msg := &common.Reply{Type: twilio.MediaEvent, Message: twilio.MediaPayload{Payload: "AAAA"}}
+
+		actual, err := coder.Encode(msg)
+
+		require.NoError(t, err)
+		assert.Equal(t, ws.TextFrame, actual.FrameType)
+		assert.JSONEq(t, `{"event":"media","media":{"payload":"AAAA"}}`, string(actual.Payload))
+	})
+
+	t.Run("mark", func(t *testing.T) {
+		msg := &common.Reply{Type: twilio.MarkEvent, Message: twilio.MarkPayload{Name: "ai-delta-1"}}
+
+		actual, err := coder.Encode(msg)
+
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"event":"mark","mark":{"name":"ai-delta-1"}}`, string(actual.Payload))
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		actual, err := coder.Encode(&common.Reply{Type: twilio.ClearEvent})
+
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"event":"clear"}`, string(actual.Payload))
+	})
+}
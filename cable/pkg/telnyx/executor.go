@@ -0,0 +1,59 @@
+package telnyx
+
+import (
+	"fmt"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/ws"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+// Executor drives Telnyx media streams through the Twilio executor
+type Executor struct {
+	twilio *twilio.Executor
+}
+
+var _ twilio.CommandExecutor = (*Executor)(nil)
+
+func NewExecutor(node node.AppNode, c *twilio.Config, opts ...twilio.ExecutorOption) *Executor {
+	// Telnyx streams have no Twilio account information
+	conf := *c
+	conf.AccountSID = ""
+
+	return &Executor{twilio: twilio.NewExecutor(node, &conf, opts...)}
+}
+
+func (ex *Executor) HandleCommand(s *node.Session, msg *common.Message) error {
+	if msg.Command == twilio.StartEvent {
+		start, ok := msg.Data.(StartPayload)
+
+		if !ok {
+			return fmt.Errorf("Malformed start message: %v", msg.Data)
+		}
+
+		// Only bidirectional PCMU streams are supported (the agent works with μ-law)
+		if start.MediaFormat.Encoding != "" && start.MediaFormat.Encoding != "PCMU" {
+			s.Log.Warn("unsupported media format", "encoding", start.MediaFormat.Encoding)
+			s.Disconnect("Unsupported Media Format", ws.CloseNormalClosure)
+			return nil
+		}
+
+		msg = &common.Message{
+			Command:    msg.Command,
+			Identifier: msg.Identifier,
+			Data:       twilio.StartPayload{CallSID: start.CallControlID, StreamSID: msg.Identifier},
+		}
+	}
+
+	return ex.twilio.HandleCommand(s, msg)
+}
+
+func (ex *Executor) HandleAppCommand(s *node.Session, cmd *twilio.AppResponse) {
+	ex.twilio.HandleAppCommand(s, cmd)
+}
+
+func (ex *Executor) Disconnect(s *node.Session) error {
+	return ex.twilio.Disconnect(s)
+}
@@ -0,0 +1,64 @@
+package telnyx
+
+// See https://developers.telnyx.com/docs/voice/programmable-voice/media-streaming
+const (
+	ConnectedEvent = "connected"
+	StartEvent     = "start"
+	MediaEvent     = "media"
+	MarkEvent      = "mark"
+	StopEvent      = "stop"
+	ClearEvent     = "clear"
+	DTMFEvent      = "dtmf"
+	ErrorEvent     = "error"
+)
+
+type MediaFormat struct {
+	Encoding   string `json:"encoding"`
+	SampleRate int    `json:"sample_rate"`
+	Channels   int    `json:"channels"`
+}
+
+type StartPayload struct {
+	UserID        string      `json:"user_id"`
+	CallControlID string      `json:"call_control_id"`
+	CallSessionID string      `json:"call_session_id"`
+	From          string      `json:"from"`
+	To            string      `json:"to"`
+	ClientState   string      `json:"client_state"`
+	MediaFormat   MediaFormat `json:"media_format"`
+}
+
+type MediaPayload struct {
+	Track     string `json:"track,omitempty"`
+	Chunk     string `json:"chunk,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Payload   string `json:"payload"`
+}
+
+type StopPayload struct {
+	UserID        string `json:"user_id"`
+	CallControlID string `json:"call_control_id"`
+}
+
+type MarkPayload struct {
+	Name string `json:"name"`
+}
+
+type DTMFPayload struct {
+	Digit string `json:"digit"`
+}
+
+// Message is a generic Telnyx media streaming message (both directions)
+type Message struct {
+	Event      string `json:"event"`
+	StreamID   string `json:"stream_id,omitempty"`
+	Seq        string `json:"sequence_number,omitempty"`
+	Version    string `json:"version,omitempty"`
+	OccurredAt string `json:"occurred_at,omitempty"`
+
+	Start *StartPayload `json:"start,omitempty"`
+	Media *MediaPayload `json:"media,omitempty"`
+	Stop  *StopPayload  `json:"stop,omitempty"`
+	Mark  *MarkPayload  `json:"mark,omitempty"`
+	DTMF  *DTMFPayload  `json:"dtmf,omitempty"`
+}
@@ -0,0 +1,84 @@
+package tenant
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/palkan/twilio-ai-cable/internal/envsubst"
+)
+
+// FileRegistry is a static list of tenants.
+//
+// Example (YAML or JSON):
+//
+//	tenants:
+//	  - id: acme
+//	    account_sid: AC123
+//	    auth_token: ${ACME_AUTH_TOKEN}
+//	    agent:
+//	      api_key: ${ACME_OPENAI_API_KEY}
+//	      voice: alloy
+type FileRegistry struct {
+	tenants map[string]*Tenant
+}
+
+var _ Registry = (*FileRegistry)(nil)
+
+// LoadFile reads tenants from the YAML or JSON file.
+// Environment variables references (e.g., ${ACME_AUTH_TOKEN}) are expanded; other dollar signs are kept as is.
+func LoadFile(path string) (*FileRegistry, error) {
+	raw, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseFile(envsubst.Expand(string(raw)))
+}
+
+// ParseFile parses a YAML or JSON list of tenants
+func ParseFile(src string) (*FileRegistry, error) {
+	var file struct {
+		Tenants []*Tenant `yaml:"tenants"`
+	}
+
+	if err := yaml.NewDecoder(strings.NewReader(src)).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse tenants: %w", err)
+	}
+
+	tenants := make(map[string]*Tenant, len(file.Tenants))
+
+	for _, t := range file.Tenants {
+		if t.ID == "" || t.AccountSID == "" {
+			return nil, fmt.Errorf("tenant must have id and account_sid (id: %q)", t.ID)
+		}
+
+		if err := t.Agent.TurnDetection.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid agent settings for tenant %s: %w", t.ID, err)
+		}
+
+		if _, ok := tenants[t.AccountSID]; ok {
+			return nil, fmt.Errorf("duplicate account SID for tenant: %s", t.ID)
+		}
+
+		tenants[t.AccountSID] = t
+	}
+
+	return &FileRegistry{tenants: tenants}, nil
+}
+
+func (r *FileRegistry) Lookup(accountSID string) (*Tenant, error) {
+	if t, ok := r.tenants[accountSID]; ok {
+		return t, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// Size returns the number of tenants
+func (r *FileRegistry) Size() int {
+	return len(r.tenants)
+}
@@ -0,0 +1,123 @@
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palkan/twilio-ai-cable/pkg/agent"
+)
+
+func TestParseFile(t *testing.T) {
+	registry, err := ParseFile(`
+tenants:
+  - id: acme
+    account_sid: AC1
+    auth_token: secret
+    agent:
+      api_key: sk-acme
+      voice: shimmer
+      turn_detection:
+        threshold: 0.8
+        silence_duration_ms: 800
+  - id: globex
+    account_sid: AC2
+    disabled: true
+`)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, registry.Size())
+
+	acme, err := registry.Lookup("AC1")
+	require.NoError(t, err)
+
+	assert.Equal(t, "acme", acme.ID)
+	assert.Equal(t, "secret", acme.AuthToken)
+	assert.Equal(t, "sk-acme", acme.Agent.APIKey)
+	assert.Equal(t, &agent.TurnDetection{Threshold: 0.8, SilenceDurationMs: 800}, acme.Agent.TurnDetection)
+	assert.False(t, acme.Disabled)
+
+	globex, err := registry.Lookup("AC2")
+	require.NoError(t, err)
+
+	assert.True(t, globex.Disabled)
+
+	_, err = registry.Lookup("AC3")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestParseFileJSON(t *testing.T) {
+	registry, err := ParseFile(`{"tenants":[{"id":"acme","account_sid":"AC1"}]}`)
+	require.NoError(t, err)
+
+	acme, err := registry.Lookup("AC1")
+	require.NoError(t, err)
+
+	assert.Equal(t, "acme", acme.ID)
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Setenv("TENANT_TEST_API_KEY", "sk-acme")
+
+	path := filepath.Join(t.TempDir(), "tenants.yml")
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+tenants:
+  - id: acme
+    account_sid: AC1
+    auth_token: pa$$w0rd
+    agent:
+      api_key: ${TENANT_TEST_API_KEY}
+`), 0o600))
+
+	registry, err := LoadFile(path)
+	require.NoError(t, err)
+
+	acme, err := registry.Lookup("AC1")
+	require.NoError(t, err)
+
+	assert.Equal(t, "pa$$w0rd", acme.AuthToken)
+	assert.Equal(t, "sk-acme", acme.Agent.APIKey)
+}
+
+func TestParseFileInvalid(t *testing.T) {
+	_, err := ParseFile(`
+tenants:
+  - id: acme
+`)
+	require.Error(t, err)
+
+	_, err = ParseFile(`
+tenants:
+  - id: acme
+    account_sid: AC1
+  - id: globex
+    account_sid: AC1
+`)
+	require.Error(t, err)
+
+	_, err = ParseFile(`
+tenants:
+  - id: acme
+    account_sid: AC1
+    agent:
+      turn_detection:
+        mode: semantic
+`)
+	require.Error(t, err)
+}
+
+func TestSettingsApply(t *testing.T) {
+	conf := agent.NewConfig("")
+	model := conf.Model
+
+	settings := Settings{APIKey: "sk-acme", Voice: "shimmer"}
+	settings.Apply(conf)
+
+	assert.Equal(t, "sk-acme", conf.Key)
+	assert.Equal(t, "shimmer", conf.Voice)
+	assert.Equal(t, model, conf.Model)
+}
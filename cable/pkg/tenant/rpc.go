@@ -0,0 +1,173 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/utils"
+	"github.com/joomcode/errorx"
+)
+
+// The app's channel to resolve tenants (see Twilio::TenantChannel)
+const Channel = "Twilio::TenantChannel"
+
+const (
+	lookupAction  = "lookup"
+	tenantEvent   = "tenant"
+	responseState = "anycable_response"
+)
+
+// RPCRegistry fetches tenants from the app by performing the lookup action of the tenant channel.
+// Found tenants are cached for the specified TTL. Missing tenants are not cached:
+// account SIDs come from callers, so the cache would grow unbounded otherwise.
+type RPCRegistry struct {
+	controller node.Controller
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	cache   map[string]*cachedTenant
+	sweptAt time.Time
+
+	log *slog.Logger
+}
+
+type cachedTenant struct {
+	tenant    *Tenant
+	expiresAt time.Time
+}
+
+var _ Registry = (*RPCRegistry)(nil)
+
+func NewRPCRegistry(ttl time.Duration, l *slog.Logger) *RPCRegistry {
+	return &RPCRegistry{
+		ttl:   ttl,
+		cache: make(map[string]*cachedTenant),
+		log:   l.With("context", "tenants"),
+	}
+}
+
+// Bind sets the RPC controller to perform lookups with
+// (the controller is created by the runner, so it's not available at the registry initialization)
+func (r *RPCRegistry) Bind(c node.Controller) {
+	r.controller = c
+}
+
+func (r *RPCRegistry) Lookup(accountSID string) (*Tenant, error) {
+	if cached, ok := r.cached(accountSID); ok {
+		return cached, nil
+	}
+
+	t, err := r.fetch(accountSID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.store(t)
+
+	return t, nil
+}
+
+func (r *RPCRegistry) cached(accountSID string) (*Tenant, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.cache[accountSID]
+
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expiresAt) {
+		delete(r.cache, accountSID)
+		return nil, false
+	}
+
+	return entry.tenant, true
+}
+
+func (r *RPCRegistry) store(t *Tenant) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	// Drop expired entries of tenants which are not looked up anymore (at most once per TTL)
+	if now.Sub(r.sweptAt) >= r.ttl {
+		for sid, entry := range r.cache {
+			if now.After(entry.expiresAt) {
+				delete(r.cache, sid)
+			}
+		}
+
+		r.sweptAt = now
+	}
+
+	r.cache[t.AccountSID] = &cachedTenant{tenant: t, expiresAt: now.Add(r.ttl)}
+}
+
+func (r *RPCRegistry) fetch(accountSID string) (*Tenant, error) {
+	if r.controller == nil {
+		return nil, errors.New("RPC controller is not bound")
+	}
+
+	identifier := string(utils.ToJSON(map[string]string{"channel": Channel}))
+	payload := string(utils.ToJSON(map[string]string{"action": lookupAction, "account_sid": accountSID}))
+
+	env := common.NewSessionEnv("", &map[string]string{})
+
+	res, err := r.controller.Perform("tenant-lookup", env, "", identifier, payload)
+
+	if err != nil {
+		return nil, errorx.Decorate(err, "failed to perform tenant lookup")
+	}
+
+	if res == nil || res.Status != common.SUCCESS {
+		return nil, ErrNotFound
+	}
+
+	rawRes := res.IState[responseState]
+
+	if rawRes == "" {
+		return nil, ErrNotFound
+	}
+
+	var reply struct {
+		Event string          `json:"event"`
+		Data  json.RawMessage `json:"data"`
+	}
+
+	if err := json.Unmarshal([]byte(rawRes), &reply); err != nil {
+		return nil, errorx.Decorate(err, "failed to parse tenant lookup response")
+	}
+
+	if reply.Event != tenantEvent {
+		return nil, fmt.Errorf("unexpected tenant lookup response: %s", reply.Event)
+	}
+
+	var t Tenant
+
+	if err := json.Unmarshal(reply.Data, &t); err != nil {
+		return nil, errorx.Decorate(err, "failed to parse tenant")
+	}
+
+	if t.ID == "" {
+		return nil, ErrNotFound
+	}
+
+	if err := t.Agent.TurnDetection.Validate(); err != nil {
+		return nil, errorx.Decorate(err, "invalid agent settings for tenant %s", t.ID)
+	}
+
+	t.AccountSID = accountSID
+
+	r.log.Debug("tenant fetched", "id", t.ID)
+
+	return &t, nil
+}
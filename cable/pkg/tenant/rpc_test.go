@@ -0,0 +1,175 @@
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantController responds to tenant lookups with the predefined tenants
+type tenantController struct {
+	mocks.MockController
+
+	tenants map[string]map[string]interface{}
+	calls   int
+	err     error
+}
+
+func (c *tenantController) Perform(sid string, env *common.SessionEnv, ids string, channel string, data string) (*common.CommandResult, error) {
+	c.calls++
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	var identifier map[string]string
+	var payload map[string]string
+
+	if err := json.Unmarshal([]byte(channel), &identifier); err != nil || identifier["channel"] != Channel {
+		return &common.CommandResult{Status: common.FAILURE}, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &payload); err != nil || payload["action"] != "lookup" {
+		return &common.CommandResult{Status: common.FAILURE}, nil
+	}
+
+	res := &common.CommandResult{Status: common.SUCCESS}
+
+	if t, ok := c.tenants[payload["account_sid"]]; ok {
+		reply, _ := json.Marshal(map[string]interface{}{"event": "tenant", "data": t})
+		res.IState = map[string]string{"anycable_response": string(reply)}
+	}
+
+	return res, nil
+}
+
+func TestRPCRegistry(t *testing.T) {
+	controller := &tenantController{
+		tenants: map[string]map[string]interface{}{
+			"AC1": {"id": "acme", "auth_token": "secret", "agent": map[string]string{"voice": "shimmer"}},
+		},
+	}
+
+	registry := NewRPCRegistry(time.Minute, slog.Default())
+
+	t.Run("when not bound", func(t *testing.T) {
+		_, err := registry.Lookup("AC1")
+		require.Error(t, err)
+	})
+
+	registry.Bind(controller)
+
+	t.Run("found", func(t *testing.T) {
+		acme, err := registry.Lookup("AC1")
+		require.NoError(t, err)
+
+		assert.Equal(t, "acme", acme.ID)
+		assert.Equal(t, "AC1", acme.AccountSID)
+		assert.Equal(t, "secret", acme.AuthToken)
+		assert.Equal(t, "shimmer", acme.Agent.Voice)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := registry.Lookup("AC2")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		calls := controller.calls
+
+		_, err := registry.Lookup("AC1")
+		require.NoError(t, err)
+
+		assert.Equal(t, calls, controller.calls)
+	})
+
+	t.Run("missing tenants are not cached", func(t *testing.T) {
+		calls := controller.calls
+
+		_, err := registry.Lookup("AC2")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		assert.Equal(t, calls+1, controller.calls)
+		assert.NotContains(t, registry.cache, "AC2")
+	})
+
+	t.Run("failures are not cached", func(t *testing.T) {
+		controller.err = errors.New("unavailable")
+
+		_, err := registry.Lookup("AC3")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, ErrNotFound)
+
+		controller.err = nil
+		controller.tenants["AC3"] = map[string]interface{}{"id": "initech"}
+
+		initech, err := registry.Lookup("AC3")
+		require.NoError(t, err)
+
+		assert.Equal(t, "initech", initech.ID)
+	})
+}
+
+func TestRPCRegistryInvalidTenant(t *testing.T) {
+	controller := &tenantController{
+		tenants: map[string]map[string]interface{}{
+			"AC1": {"id": "acme", "agent": map[string]interface{}{"turn_detection": map[string]string{"mode": "semantic"}}},
+		},
+	}
+
+	registry := NewRPCRegistry(time.Minute, slog.Default())
+	registry.Bind(controller)
+
+	_, err := registry.Lookup("AC1")
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
+
+func TestRPCRegistryExpiration(t *testing.T) {
+	controller := &tenantController{tenants: map[string]map[string]interface{}{}}
+
+	registry := NewRPCRegistry(10*time.Millisecond, slog.Default())
+	registry.Bind(controller)
+
+	_, err := registry.Lookup("AC1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	controller.tenants["AC1"] = map[string]interface{}{"id": "acme"}
+
+	time.Sleep(20 * time.Millisecond)
+
+	acme, err := registry.Lookup("AC1")
+	require.NoError(t, err)
+
+	assert.Equal(t, "acme", acme.ID)
+}
+
+func TestRPCRegistrySweep(t *testing.T) {
+	controller := &tenantController{
+		tenants: map[string]map[string]interface{}{
+			"AC1": {"id": "acme"},
+			"AC2": {"id": "globex"},
+		},
+	}
+
+	registry := NewRPCRegistry(10*time.Millisecond, slog.Default())
+	registry.Bind(controller)
+
+	_, err := registry.Lookup("AC1")
+	require.NoError(t, err)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = registry.Lookup("AC2")
+	require.NoError(t, err)
+
+	assert.NotContains(t, registry.cache, "AC1")
+	assert.Contains(t, registry.cache, "AC2")
+}
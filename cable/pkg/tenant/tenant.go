@@ -0,0 +1,70 @@
+package tenant
+
+import (
+	"errors"
+
+	"github.com/palkan/twilio-ai-cable/pkg/agent"
+)
+
+var ErrNotFound = errors.New("tenant not found")
+
+// Tenant is a customer with its own Twilio (sub)account
+type Tenant struct {
+	ID         string `yaml:"id" json:"id"`
+	AccountSID string `yaml:"account_sid" json:"account_sid"`
+	// Auth token to validate request signatures (optional)
+	AuthToken string `yaml:"auth_token" json:"auth_token"`
+	// Reject all calls for the tenant
+	Disabled bool `yaml:"disabled" json:"disabled"`
+	// Default agent settings (the app's configuration takes precedence)
+	Agent Settings `yaml:"agent" json:"agent"`
+}
+
+// Settings contains the default agent settings for the tenant
+type Settings struct {
+	Provider string `yaml:"provider" json:"provider"`
+	URL      string `yaml:"url" json:"url"`
+	APIKey   string `yaml:"api_key" json:"api_key"`
+	Model    string `yaml:"model" json:"model"`
+	Voice    string `yaml:"voice" json:"voice"`
+	Prompt   string `yaml:"prompt" json:"prompt"`
+	// Turn detection (VAD) settings, e.g., a higher threshold for noisy call centers
+	TurnDetection *agent.TurnDetection `yaml:"turn_detection" json:"turn_detection"`
+}
+
+// Apply sets the non-empty settings to the agent config
+func (st *Settings) Apply(conf *agent.Config) {
+	if st.Provider != "" {
+		conf.Provider = st.Provider
+	}
+
+	if st.URL != "" {
+		conf.URL = st.URL
+	}
+
+	if st.APIKey != "" {
+		conf.Key = st.APIKey
+	}
+
+	if st.Model != "" {
+		conf.Model = st.Model
+	}
+
+	if st.Voice != "" {
+		conf.Voice = st.Voice
+	}
+
+	if st.Prompt != "" {
+		conf.Prompt = st.Prompt
+	}
+
+	if st.TurnDetection != nil {
+		conf.TurnDetection = st.TurnDetection
+	}
+}
+
+// Registry resolves tenants by account SIDs
+type Registry interface {
+	// Lookup returns the tenant for the account SID or ErrNotFound
+	Lookup(accountSID string) (*Tenant, error)
+}
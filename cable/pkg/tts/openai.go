@@ -0,0 +1,87 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/joomcode/errorx"
+
+	"github.com/palkan/twilio-ai-cable/internal/wav"
+)
+
+// OpenAI returns raw PCM as 24kHz 16-bit mono
+const openaiSampleRate = 24000
+
+func init() {
+	Register("openai", func(c *Config, l *slog.Logger) Synthesizer {
+		return NewOpenAISynthesizer(c, l)
+	})
+}
+
+// OpenAISynthesizer uses OpenAI speech API
+type OpenAISynthesizer struct {
+	conf   *Config
+	client *http.Client
+	log    *slog.Logger
+}
+
+var _ Synthesizer = (*OpenAISynthesizer)(nil)
+
+func NewOpenAISynthesizer(c *Config, l *slog.Logger) *OpenAISynthesizer {
+	return &OpenAISynthesizer{conf: c, client: &http.Client{}, log: l.With("tts", "openai")}
+}
+
+func (s *OpenAISynthesizer) Synthesize(ctx context.Context, text string, voice string) ([]byte, error) {
+	if voice == "" {
+		voice = s.conf.Voice
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"model":           s.conf.Model,
+		"input":           text,
+		"voice":           voice,
+		"response_format": "pcm",
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.conf.URL, bytes.NewReader(payload))
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+s.conf.Key)
+	req.Header.Set("Content-Type", "application/json")
+
+	s.log.Debug("synthesizing speech", "text", text, "voice", voice)
+
+	res, err := s.client.Do(req)
+
+	if err != nil {
+		return nil, errorx.Decorate(err, "failed to perform TTS request")
+	}
+
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+
+	if err != nil {
+		return nil, errorx.Decorate(err, "failed to read TTS response")
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TTS request failed with status %d: %s", res.StatusCode, body)
+	}
+
+	format := wav.PCMFormat(1, openaiSampleRate)
+
+	return wav.ToMulaw(&format, body)
+}
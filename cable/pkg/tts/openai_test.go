@@ -0,0 +1,81 @@
+package tts
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAISynthesizer(t *testing.T) {
+	var received map[string]string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if received["input"] == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"no input"}`)) // nolint:errcheck
+			return
+		}
+
+		// 20ms of 24kHz 16-bit silence
+		w.Write(make([]byte, 960)) // nolint:errcheck
+	}))
+	defer srv.Close()
+
+	conf := NewConfig()
+	conf.Provider = "openai"
+	conf.URL = srv.URL
+	conf.Key = "sk-test"
+
+	synth, err := New(conf, slog.Default())
+	require.NoError(t, err)
+
+	t.Run("default voice", func(t *testing.T) {
+		audio, err := synth.Synthesize(context.Background(), "Hello", "")
+		require.NoError(t, err)
+
+		// 20ms of 8kHz μ-law
+		assert.Len(t, audio, 160)
+
+		assert.Equal(t, "Hello", received["input"])
+		assert.Equal(t, "alloy", received["voice"])
+		assert.Equal(t, "tts-1", received["model"])
+		assert.Equal(t, "pcm", received["response_format"])
+	})
+
+	t.Run("custom voice", func(t *testing.T) {
+		_, err := synth.Synthesize(context.Background(), "Hello", "shimmer")
+		require.NoError(t, err)
+
+		assert.Equal(t, "shimmer", received["voice"])
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := synth.Synthesize(context.Background(), "", "")
+		require.Error(t, err)
+
+		assert.Contains(t, err.Error(), "no input")
+	})
+}
+
+func TestNew(t *testing.T) {
+	assert.Contains(t, Backends(), "openai")
+
+	conf := NewConfig()
+	conf.Provider = "unknown"
+
+	_, err := New(conf, slog.Default())
+	require.Error(t, err)
+}
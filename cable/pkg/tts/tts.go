@@ -0,0 +1,82 @@
+// Package tts provides text-to-speech backends used to speak the app's phrases
+// when there is no realtime agent in the call.
+package tts
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// Synthesizer converts text to speech
+type Synthesizer interface {
+	// Synthesize returns the speech as 8kHz μ-law audio (the default voice is used if voice is empty)
+	Synthesize(ctx context.Context, text string, voice string) ([]byte, error)
+}
+
+type Config struct {
+	// Backend name (see Register), TTS is disabled if empty
+	Provider string
+	URL      string
+	Key      string
+	Model    string
+	Voice    string
+}
+
+func NewConfig() *Config {
+	return &Config{
+		URL:   "https://api.openai.com/v1/audio/speech",
+		Model: "tts-1",
+		Voice: "alloy",
+	}
+}
+
+func (c *Config) Enabled() bool {
+	return c.Provider != ""
+}
+
+type Factory = func(c *Config, l *slog.Logger) Synthesizer
+
+var (
+	backends   = make(map[string]Factory)
+	backendsMu sync.RWMutex
+)
+
+// Register makes a backend available by the given name
+func Register(name string, factory Factory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	backends[name] = factory
+}
+
+// Backends returns the names of the registered backends
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+
+	for name := range backends {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// New creates a synthesizer for the configured backend
+func New(c *Config, l *slog.Logger) (Synthesizer, error) {
+	backendsMu.RLock()
+	factory, ok := backends[c.Provider]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown TTS backend: %s", c.Provider)
+	}
+
+	return factory(c, l), nil
+}
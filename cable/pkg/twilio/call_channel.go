@@ -0,0 +1,81 @@
+package twilio
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/utils"
+)
+
+// CallChannel is a server-side channel streaming app commands for a particular call.
+// It must be added to the runner's router (so subscriptions are not sent to the app via RPC).
+const CallChannel = "Twilio::CallChannel"
+
+// CallStream returns the name of the stream to broadcast commands to the call
+func CallStream(callSid string) string {
+	return "call_" + callSid
+}
+
+// CallController handles CallChannel subscriptions
+type CallController struct {
+	log *slog.Logger
+}
+
+var _ node.Controller = (*CallController)(nil)
+
+func NewCallController(l *slog.Logger) *CallController {
+	return &CallController{log: l.With("context", "call_channel")}
+}
+
+func (c *CallController) Start() error {
+	return nil
+}
+
+func (c *CallController) Shutdown() error {
+	return nil
+}
+
+func (c *CallController) Authenticate(sid string, env *common.SessionEnv) (*common.ConnectResult, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *CallController) Subscribe(sid string, env *common.SessionEnv, ids string, channel string) (*common.CommandResult, error) {
+	var identifiers struct {
+		CallSID string `json:"call_sid"`
+	}
+
+	if err := json.Unmarshal([]byte(ids), &identifiers); err != nil || identifiers.CallSID == "" {
+		c.log.With("sid", sid).Debug("rejected subscription: no call SID", "ids", ids)
+
+		return &common.CommandResult{Status: common.FAILURE}, nil
+	}
+
+	stream := CallStream(identifiers.CallSID)
+
+	c.log.With("sid", sid).Debug("subscribed", "stream", stream)
+
+	return &common.CommandResult{Status: common.SUCCESS, Streams: []string{stream}}, nil
+}
+
+func (c *CallController) Unsubscribe(sid string, env *common.SessionEnv, ids string, channel string) (*common.CommandResult, error) {
+	return &common.CommandResult{Status: common.SUCCESS, StopAllStreams: true}, nil
+}
+
+func (c *CallController) Perform(sid string, env *common.SessionEnv, ids string, channel string, data string) (*common.CommandResult, error) {
+	return nil, errors.New("actions are not supported")
+}
+
+func (c *CallController) Disconnect(sid string, env *common.SessionEnv, ids string, subscriptions []string) error {
+	return nil
+}
+
+func callChannelId() string {
+	msg := struct {
+		Channel string `json:"channel"`
+	}{Channel: CallChannel}
+
+	return string(utils.ToJSON(msg))
+}
@@ -0,0 +1,43 @@
+package twilio
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallController(t *testing.T) {
+	controller := NewCallController(slog.Default())
+
+	t.Run("subscribe", func(t *testing.T) {
+		res, err := controller.Subscribe("42", nil, `{"call_sid":"ca123","stream_sid":"sm123"}`, callChannelId())
+		require.NoError(t, err)
+
+		assert.Equal(t, common.SUCCESS, res.Status)
+		assert.Equal(t, []string{"call_ca123"}, res.Streams)
+	})
+
+	t.Run("subscribe without call SID", func(t *testing.T) {
+		res, err := controller.Subscribe("42", nil, `{"stream_sid":"sm123"}`, callChannelId())
+		require.NoError(t, err)
+
+		assert.Equal(t, common.FAILURE, res.Status)
+		assert.Empty(t, res.Streams)
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		res, err := controller.Unsubscribe("42", nil, `{"call_sid":"ca123"}`, callChannelId())
+		require.NoError(t, err)
+
+		assert.Equal(t, common.SUCCESS, res.Status)
+		assert.True(t, res.StopAllStreams)
+	})
+
+	t.Run("perform", func(t *testing.T) {
+		_, err := controller.Perform("42", nil, `{"call_sid":"ca123"}`, callChannelId(), `{"action":"hangup"}`)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,264 @@
+package twilio
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/encoders"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/ws"
+)
+
+// App commands are the app responses that could be sent at any time during the call:
+// either as RPC responses (to any action) or via broadcasts to the channel streams.
+// Broadcasts must have the same format as RPC responses: {"event": "...", "data": {...}}
+const (
+	sessionUpdateEvent = "openai.session_update"
+	// Add a text message from the user to the conversation (the agent responds to it)
+	userMessageEvent = "openai.user_message"
+	// Make the agent respond (optionally, following the instructions)
+	responseEvent = "openai.response"
+	// Commit the caller's audio and make the agent respond (when turn detection is disabled)
+	commitEvent = "openai.commit"
+	// Make the agent say the text as is (interrupting the current playback)
+	sayEvent = "openai.say"
+	// Stop the current playback (and the agent's response)
+	clearEvent = "call.clear"
+	// Play a μ-law clip to the caller
+	playEvent = "call.play"
+	// Close the stream
+	hangupEvent = "call.hangup"
+)
+
+var appCommands = map[string]bool{
+	sessionUpdateEvent: true,
+	userMessageEvent:   true,
+	responseEvent:      true,
+	commitEvent:        true,
+	sayEvent:           true,
+	clearEvent:         true,
+	playEvent:          true,
+	hangupEvent:        true,
+}
+
+func isAppCommand(event string) bool {
+	return appCommands[event]
+}
+
+// CommandExecutor is an executor capable of handling app commands
+type CommandExecutor interface {
+	node.Executor
+	HandleAppCommand(s *node.Session, cmd *AppResponse)
+}
+
+// NewSession creates a session accepting app commands via broadcasts and transmissions.
+//
+// Broadcasts are delivered to the session through the encoder (and encoded frames are cached
+// and shared between sessions), so we can't handle commands there.
+// Instead, the encoder marks command frames and the connection intercepts them before they're sent to the carrier.
+func NewSession(n *node.Node, conn node.Connection, url string, headers *map[string]string, uid string, enc encoders.Encoder, executor CommandExecutor, opts ...node.SessionOption) *node.Session {
+	var session *node.Session
+
+	cmdConn := &CommandConnection{
+		Connection: conn,
+		handler: func(cmd *AppResponse) {
+			executor.HandleAppCommand(session, cmd)
+		},
+	}
+
+	opts = append([]node.SessionOption{node.WithEncoder(NewCommandEncoder(enc, cmdConn)), node.WithExecutor(executor)}, opts...)
+
+	session = node.NewSession(n, cmdConn, url, headers, uid, opts...)
+
+	return session
+}
+
+// Command frames are prefixed with this marker (it can't appear in JSON or μ-law media)
+var commandMarker = []byte("\x00app-command:")
+
+// CommandEncoder wraps the transport encoder to mark app commands.
+// Command frames are only produced when the encoder is paired with the connection intercepting them,
+// so they never reach the carrier; otherwise, the encoder acts as the wrapped one.
+type CommandEncoder struct {
+	encoders.Encoder
+
+	conn *CommandConnection
+}
+
+// NewCommandEncoder creates an encoder marking app commands for the given connection
+func NewCommandEncoder(enc encoders.Encoder, conn *CommandConnection) *CommandEncoder {
+	return &CommandEncoder{Encoder: enc, conn: conn}
+}
+
+func (e *CommandEncoder) ID() string {
+	// Encoded frames are shared between sessions with the same encoder ID,
+	// so unpaired encoders must not share command frames with paired ones
+	if e.conn == nil {
+		return e.Encoder.ID()
+	}
+
+	return e.Encoder.ID() + "+commands"
+}
+
+func (e *CommandEncoder) Encode(msg encoders.EncodedMessage) (*ws.SentFrame, error) {
+	if e.conn == nil {
+		return e.Encoder.Encode(msg)
+	}
+
+	if r, ok := msg.(*common.Reply); ok {
+		if cmd := toAppCommand(r.Message); cmd != nil {
+			return commandFrame(cmd), nil
+		}
+	}
+
+	return e.Encoder.Encode(msg)
+}
+
+// EncodeTransmission marks app commands transmitted from the channel (e.g., `transmit(event: "openai.say", ...)`)
+func (e *CommandEncoder) EncodeTransmission(raw string) (*ws.SentFrame, error) {
+	if e.conn == nil {
+		return e.Encoder.EncodeTransmission(raw)
+	}
+
+	msg := common.Reply{}
+
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		return nil, err
+	}
+
+	if cmd := toAppCommand(msg.Message); cmd != nil {
+		return commandFrame(cmd), nil
+	}
+
+	return e.Encoder.EncodeTransmission(raw)
+}
+
+func commandFrame(cmd []byte) *ws.SentFrame {
+	return &ws.SentFrame{FrameType: ws.TextFrame, Payload: append(append([]byte{}, commandMarker...), cmd...)}
+}
+
+// CommandConnection intercepts app commands and passes them to the handler.
+// Frames are written while holding the session's lock, so commands are handled in a separate goroutine
+// (one at a time, in the order they were received).
+type CommandConnection struct {
+	node.Connection
+
+	handler func(cmd *AppResponse)
+
+	queue []*AppResponse
+	busy  bool
+	mu    sync.Mutex
+}
+
+func (c *CommandConnection) Write(msg []byte, deadline time.Time) error {
+	if bytes.HasPrefix(msg, commandMarker) {
+		var cmd AppResponse
+
+		if err := json.Unmarshal(msg[len(commandMarker):], &cmd); err == nil {
+			c.enqueue(&cmd)
+		}
+
+		return nil
+	}
+
+	return c.Connection.Write(msg, deadline)
+}
+
+func (c *CommandConnection) enqueue(cmd *AppResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.queue = append(c.queue, cmd)
+
+	if !c.busy {
+		c.busy = true
+		go c.drain()
+	}
+}
+
+func (c *CommandConnection) drain() {
+	for {
+		c.mu.Lock()
+
+		if len(c.queue) == 0 {
+			c.busy = false
+			c.mu.Unlock()
+			return
+		}
+
+		cmd := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mu.Unlock()
+
+		c.handler(cmd)
+	}
+}
+
+// commandBuffer holds the commands requiring the agent received before the agent is initialized
+// (e.g., the greeting transmitted from the channel's #subscribed callback)
+type commandBuffer struct {
+	cmds    []*AppResponse
+	flushed bool
+	mu      sync.Mutex
+}
+
+// Add buffers the command; returns false if the buffer has been already flushed
+func (b *commandBuffer) Add(cmd *AppResponse) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.flushed {
+		return false
+	}
+
+	b.cmds = append(b.cmds, cmd)
+
+	return true
+}
+
+// Flush returns the buffered commands and stops buffering
+func (b *commandBuffer) Flush() []*AppResponse {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.flushed = true
+	cmds := b.cmds
+	b.cmds = nil
+
+	return cmds
+}
+
+// agentCommands are the app commands that require the agent (if it's expected)
+var agentCommands = map[string]bool{
+	sessionUpdateEvent: true,
+	userMessageEvent:   true,
+	responseEvent:      true,
+	commitEvent:        true,
+	sayEvent:           true,
+}
+
+// toAppCommand returns the command JSON if the broadcasted message is an app command
+func toAppCommand(msg interface{}) []byte {
+	data, ok := msg.(map[string]interface{})
+
+	if !ok {
+		return nil
+	}
+
+	event, ok := data["event"].(string)
+
+	if !ok || !isAppCommand(event) {
+		return nil
+	}
+
+	b, err := json.Marshal(data)
+
+	if err != nil {
+		return nil
+	}
+
+	return b
+}
@@ -0,0 +1,521 @@
+package twilio
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/encoders"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/node_mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palkan/twilio-ai-cable/internal/fake_openai"
+)
+
+func TestCommandEncoder(t *testing.T) {
+	enc := NewCommandEncoder(Encoder{}, &CommandConnection{Connection: mocks.NewMockConnection()})
+
+	assert.Equal(t, "twilio+commands", enc.ID())
+
+	t.Run("app command", func(t *testing.T) {
+		msg := &common.Reply{Identifier: channelName, Message: map[string]interface{}{"event": sessionUpdateEvent, "data": map[string]interface{}{"prompt": "Hi"}}}
+
+		frame, err := enc.Encode(msg)
+		require.NoError(t, err)
+
+		handled := make(chan *AppResponse, 1)
+
+		conn := &CommandConnection{Connection: mocks.NewMockConnection(), handler: func(cmd *AppResponse) { handled <- cmd }}
+
+		require.NoError(t, conn.Write(frame.Payload, time.Now()))
+
+		cmd := <-handled
+
+		assert.Equal(t, sessionUpdateEvent, cmd.Event)
+		assert.JSONEq(t, `{"prompt":"Hi"}`, string(cmd.Data))
+	})
+
+	t.Run("transmitted app command", func(t *testing.T) {
+		frame, err := enc.EncodeTransmission(`{"identifier":"test","message":{"event":"openai.say","data":{"text":"Hello"}}}`)
+		require.NoError(t, err)
+
+		handled := make(chan *AppResponse, 1)
+
+		conn := &CommandConnection{Connection: mocks.NewMockConnection(), handler: func(cmd *AppResponse) { handled <- cmd }}
+
+		require.NoError(t, conn.Write(frame.Payload, time.Now()))
+
+		cmd := <-handled
+
+		assert.Equal(t, sayEvent, cmd.Event)
+		assert.JSONEq(t, `{"text":"Hello"}`, string(cmd.Data))
+	})
+
+	t.Run("commands order", func(t *testing.T) {
+		handled := make(chan string, 10)
+
+		conn := &CommandConnection{Connection: mocks.NewMockConnection(), handler: func(cmd *AppResponse) {
+			time.Sleep(time.Millisecond)
+			handled <- string(cmd.Data)
+		}}
+
+		for i := 0; i < 5; i++ {
+			frame, err := enc.Encode(&common.Reply{Message: map[string]interface{}{"event": sayEvent, "data": i}})
+			require.NoError(t, err)
+
+			require.NoError(t, conn.Write(frame.Payload, time.Now()))
+		}
+
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, strconv.Itoa(i), <-handled)
+		}
+	})
+
+	t.Run("other broadcasts", func(t *testing.T) {
+		msg := &common.Reply{Identifier: channelName, Message: map[string]interface{}{"event": "clear", "streamSid": "sm123"}}
+
+		frame, err := enc.Encode(msg)
+		require.NoError(t, err)
+
+		assert.JSONEq(t, `{"event":"clear","streamSid":"sm123"}`, string(frame.Payload))
+
+		inner := mocks.NewMockConnection()
+		conn := &CommandConnection{Connection: inner, handler: func(cmd *AppResponse) { t.Fatal("unexpected command") }}
+
+		require.NoError(t, conn.Write(frame.Payload, time.Now()))
+
+		written, err := inner.Read()
+		require.NoError(t, err)
+		assert.Equal(t, frame.Payload, written)
+	})
+
+	t.Run("without command connection", func(t *testing.T) {
+		plain := &CommandEncoder{Encoder: Encoder{}}
+
+		assert.Equal(t, "twilio", plain.ID())
+
+		frame, err := plain.Encode(&common.Reply{Identifier: channelName, Message: map[string]interface{}{"event": sayEvent, "data": map[string]interface{}{"text": "Hi"}}})
+		require.NoError(t, err)
+
+		if frame != nil {
+			assert.NotContains(t, string(frame.Payload), string(commandMarker))
+		}
+
+		frame, err = plain.EncodeTransmission(`{"identifier":"test","message":{"event":"openai.say","data":{"text":"Hello"}}}`)
+		require.NoError(t, err)
+
+		if frame != nil {
+			assert.NotContains(t, string(frame.Payload), string(commandMarker))
+		}
+	})
+}
+
+func TestSessionUpdate(t *testing.T) {
+	srv := fake_openai.NewServer()
+	defer srv.Close()
+
+	app := &node_mocks.AppNode{}
+	n := NewMockNode()
+	c := NewConfig()
+	executor := NewExecutor(app, c)
+
+	conn := mocks.NewMockConnection()
+	session := NewSession(n, conn, "ws://anycable.io/twilio", nil, "sess-1", Encoder{}, executor)
+	session.Connected = true
+	session.Log = slog.With("context", "test")
+
+	app.On("Authenticated", session, mock.Anything)
+	app.On("Disconnect", session).Return(nil)
+	app.On("Perform", session, performAction("handle_usage")).Return(nil, nil)
+	app.
+		On("Subscribe", session, &common.Message{Identifier: channelId(session), Command: "subscribe"}).
+		Return(nil, nil)
+
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"configure_openai"}`}).
+		Return(appResponse(configEvent, OpenAIConfigData{APIKey: "sk-test", URL: srv.URL(), Prompt: "Be nice"}), nil)
+
+	app.
+		On("Perform", session, performAction("handle_transcript")).
+		Return(appResponse(sessionUpdateEvent, SessionUpdateData{Prompt: "You're a billing assistant", Tools: `[{"type":"function","name":"pay"}]`}), nil)
+
+	start := StartPayload{AccountSID: "ac42", CallSID: "ca123", StreamSID: "sm123"}
+
+	err := executor.HandleCommand(session, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start})
+	require.NoError(t, err)
+
+	defer executor.Disconnect(session) // nolint:errcheck
+
+	ai, err := srv.NextConn(2 * time.Second)
+	require.NoError(t, err)
+
+	t.Run("via RPC response", func(t *testing.T) {
+		require.NoError(t, ai.SendInputTranscript("item_1", "I'd like to pay my bill"))
+
+		msg := waitForSessionUpdate(t, ai, "You're a billing assistant")
+		assert.Contains(t, msg, `"name":"pay"`)
+	})
+
+	t.Run("via broadcast", func(t *testing.T) {
+		broadcastCommand(session, sessionUpdateEvent, map[string]string{"prompt": "You're a support assistant", "voice": "shimmer"})
+
+		msg := waitForSessionUpdate(t, ai, "You're a support assistant")
+		assert.Contains(t, msg, `"voice":"shimmer"`)
+		// Tools are left intact
+		assert.Contains(t, msg, `"name":"pay"`)
+
+		// Nothing is sent to Twilio
+		_, err := conn.Read()
+		require.Error(t, err)
+	})
+}
+
+func TestCallCommands(t *testing.T) {
+	srv := fake_openai.NewServer()
+	defer srv.Close()
+
+	app := &node_mocks.AppNode{}
+	n := NewMockNode()
+	c := NewConfig()
+	executor := NewExecutor(app, c, WithCallChannel())
+
+	conn := mocks.NewMockConnection()
+	session := NewSession(n, conn, "ws://anycable.io/twilio", nil, "sess-1", Encoder{}, executor)
+	session.Connected = true
+	session.Log = slog.With("context", "test")
+
+	app.On("Authenticated", session, mock.Anything)
+	app.On("Disconnect", session).Return(nil)
+	app.On("Perform", session, performAction("handle_usage")).Return(nil, nil)
+	app.
+		On("Subscribe", session, &common.Message{Identifier: channelId(session), Command: "subscribe"}).
+		Return(nil, nil)
+	app.
+		On("Subscribe", session, &common.Message{Identifier: callChannelId(), Command: "subscribe"}).
+		Return(nil, nil)
+	app.
+		On("Unsubscribe", session, &common.Message{Identifier: callChannelId(), Command: "unsubscribe"}).
+		Return(nil, nil)
+
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"configure_openai"}`}).
+		Return(appResponse(configEvent, OpenAIConfigData{APIKey: "sk-test", URL: srv.URL()}), nil)
+
+	start := StartPayload{AccountSID: "ac42", CallSID: "ca123", StreamSID: "sm123"}
+
+	err := executor.HandleCommand(session, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start})
+	require.NoError(t, err)
+
+	app.AssertCalled(t, "Subscribe", session, &common.Message{Identifier: callChannelId(), Command: "subscribe"})
+
+	ai, err := srv.NextConn(2 * time.Second)
+	require.NoError(t, err)
+
+	t.Run("user message", func(t *testing.T) {
+		broadcastCommand(session, userMessageEvent, UserMessageData{Text: "I'm back"})
+
+		waitForMessage(t, ai, `{"type":"input_text","text":"I'm back"}`)
+	})
+
+	t.Run("response", func(t *testing.T) {
+		broadcastCommand(session, responseEvent, ResponseData{Instructions: "Ask if the caller is still there"})
+
+		waitForMessage(t, ai, `"instructions":"Ask if the caller is still there"`)
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		broadcastCommand(session, commitEvent, nil)
+
+		waitForMessage(t, ai, `{"type":"input_audio_buffer.commit"}`)
+	})
+
+	t.Run("play", func(t *testing.T) {
+		broadcastCommand(session, playEvent, PlayData{Audio: "//8="})
+
+		msg, err := conn.Read()
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"event":"media","streamSid":"sm123","media":{"payload":"//8=","track":""}}`, string(msg))
+
+		msg, err = conn.Read()
+		require.NoError(t, err)
+		assert.Contains(t, string(msg), `"event":"mark"`)
+
+		assert.Equal(t, 1, executor.getPlayback(session).Pending())
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		broadcastCommand(session, clearEvent, nil)
+
+		msg, err := conn.Read()
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"event":"clear","streamSid":"sm123"}`, string(msg))
+
+		assert.Equal(t, 0, executor.getPlayback(session).Pending())
+	})
+
+	t.Run("hangup", func(t *testing.T) {
+		broadcastCommand(session, hangupEvent, nil)
+
+		require.Eventually(t, session.IsClosed, 2*time.Second, 10*time.Millisecond)
+
+		app.AssertCalled(t, "Unsubscribe", session, &common.Message{Identifier: callChannelId(), Command: "unsubscribe"})
+		app.AssertCalled(t, "Disconnect", session)
+	})
+}
+
+type fakeSynthesizer struct {
+	texts chan string
+	// Blocks synthesis until closed (if set)
+	release chan struct{}
+}
+
+func (s *fakeSynthesizer) Synthesize(ctx context.Context, text string, voice string) ([]byte, error) {
+	s.texts <- text + "/" + voice
+
+	if s.release != nil {
+		<-s.release
+	}
+
+	return []byte{0xff, 0xff}, nil
+}
+
+func TestTransmittedSayWithoutAgent(t *testing.T) {
+	app := &node_mocks.AppNode{}
+	n := NewMockNode()
+	c := NewConfig()
+	synth := &fakeSynthesizer{texts: make(chan string, 1)}
+	executor := NewExecutor(app, c, WithSynthesizer(synth))
+
+	conn := mocks.NewMockConnection()
+	session := NewSession(n, conn, "ws://anycable.io/twilio", nil, "sess-1", Encoder{}, executor)
+	session.Connected = true
+	session.Log = slog.With("context", "test")
+
+	app.On("Authenticated", session, mock.Anything)
+	app.
+		On("Subscribe", session, &common.Message{Identifier: channelId(session), Command: "subscribe"}).
+		Return(nil, nil)
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"configure_openai"}`}).
+		Return(nil, nil)
+
+	start := StartPayload{AccountSID: "ac42", CallSID: "ca123", StreamSID: "sm123"}
+
+	err := executor.HandleCommand(session, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start})
+	require.NoError(t, err)
+
+	session.SendJSONTransmission(`{"identifier":"test","message":{"event":"openai.say","data":{"text":"Press 1 to check tasks","voice":"nova"}}}`)
+
+	select {
+	case text := <-synth.texts:
+		assert.Equal(t, "Press 1 to check tasks/nova", text)
+	case <-time.After(2 * time.Second):
+		t.Fatal("text hasn't been synthesized")
+	}
+
+	msg, err := conn.Read()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"event":"clear","streamSid":"sm123"}`, string(msg))
+
+	msg, err = conn.Read()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"event":"media","streamSid":"sm123","media":{"payload":"//8=","track":""}}`, string(msg))
+
+	msg, err = conn.Read()
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), `"event":"mark"`)
+}
+
+// broadcastCommand emulates the app's broadcast to the call stream
+func broadcastCommand(s *node.Session, event string, data interface{}) {
+	broadcast := common.StreamMessage{Stream: CallStream("ca123"), Data: string(toJSON(map[string]interface{}{
+		"event": event,
+		"data":  data,
+	}))}
+
+	s.Send(encoders.NewCachedEncodedMessage(broadcast.ToReplyFor(callChannelId())))
+}
+
+func waitForMessage(t *testing.T, ai *fake_openai.Conn, fragment string) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		for _, raw := range ai.Received() {
+			if strings.Contains(string(raw), fragment) {
+				return true
+			}
+		}
+
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func waitForSessionUpdate(t *testing.T, ai *fake_openai.Conn, instructions string) string {
+	t.Helper()
+
+	var found string
+
+	require.Eventually(t, func() bool {
+		for _, raw := range ai.Received() {
+			var msg struct {
+				Type    string `json:"type"`
+				Session struct {
+					Instructions string `json:"instructions"`
+				} `json:"session"`
+			}
+
+			_ = json.Unmarshal(raw, &msg)
+
+			if msg.Type == "session.update" && msg.Session.Instructions == instructions {
+				found = string(raw)
+				return true
+			}
+		}
+
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+
+	return found
+}
+
+func TestSayWithoutAgentFromRPC(t *testing.T) {
+	app := &node_mocks.AppNode{}
+	n := NewMockNode()
+	c := NewConfig()
+	synth := &fakeSynthesizer{texts: make(chan string, 2), release: make(chan struct{})}
+	executor := NewExecutor(app, c, WithSynthesizer(synth))
+
+	conn := mocks.NewMockConnection()
+	session := NewSession(n, conn, "ws://anycable.io/twilio", nil, "sess-1", Encoder{}, executor)
+	session.Connected = true
+	session.Log = slog.With("context", "test")
+
+	app.On("Authenticated", session, mock.Anything)
+	app.
+		On("Subscribe", session, &common.Message{Identifier: channelId(session), Command: "subscribe"}).
+		Return(nil, nil)
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"configure_openai"}`}).
+		Return(nil, nil)
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"handle_dtmf","digit":"1"}`}).
+		Return(appResponse(sayEvent, SayData{Text: "First"}), nil)
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"handle_dtmf","digit":"2"}`}).
+		Return(appResponse(sayEvent, SayData{Text: "Second"}), nil)
+
+	start := StartPayload{AccountSID: "ac42", CallSID: "ca123", StreamSID: "sm123"}
+
+	err := executor.HandleCommand(session, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start})
+	require.NoError(t, err)
+
+	// Synthesis is blocked, but the stream keeps being handled
+	handled := make(chan struct{})
+
+	go func() {
+		defer close(handled)
+
+		assert.NoError(t, executor.HandleCommand(session, &common.Message{Command: DTMFEvent, Data: DTMFPayload{Digit: "1"}}))
+		assert.NoError(t, executor.HandleCommand(session, &common.Message{Command: DTMFEvent, Data: DTMFPayload{Digit: "2"}}))
+	}()
+
+	select {
+	case <-handled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("synthesis blocks the stream")
+	}
+
+	assert.Equal(t, "First/", <-synth.texts)
+
+	close(synth.release)
+
+	// Phrases are synthesized in order
+	select {
+	case text := <-synth.texts:
+		assert.Equal(t, "Second/", text)
+	case <-time.After(2 * time.Second):
+		t.Fatal("second text hasn't been synthesized")
+	}
+
+	for i := 0; i < 2; i++ {
+		msg, err := conn.Read()
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"event":"clear","streamSid":"sm123"}`, string(msg))
+
+		msg, err = conn.Read()
+		require.NoError(t, err)
+		assert.Contains(t, string(msg), `"event":"media"`)
+
+		msg, err = conn.Read()
+		require.NoError(t, err)
+		assert.Contains(t, string(msg), `"event":"mark"`)
+	}
+}
+
+func TestSayBeforeAgentInitialized(t *testing.T) {
+	srv := fake_openai.NewServer()
+	defer srv.Close()
+
+	app := &node_mocks.AppNode{}
+	n := NewMockNode()
+	c := NewConfig()
+	synth := &fakeSynthesizer{texts: make(chan string, 1)}
+	executor := NewExecutor(app, c, WithSynthesizer(synth))
+
+	conn := mocks.NewMockConnection()
+	session := NewSession(n, conn, "ws://anycable.io/twilio", nil, "sess-1", Encoder{}, executor)
+	session.Connected = true
+	session.Log = slog.With("context", "test")
+
+	app.On("Authenticated", session, mock.Anything)
+	app.On("Disconnect", session).Return(nil)
+	app.On("Perform", session, performAction("handle_usage")).Return(nil, nil)
+	app.
+		On("Subscribe", session, &common.Message{Identifier: channelId(session), Command: "subscribe"}).
+		Run(func(mock.Arguments) {
+			// The channel greets the caller right on subscribe
+			session.SendJSONTransmission(`{"identifier":"test","message":{"event":"openai.say","data":{"text":"Hi, I'm Alloy"}}}`)
+
+			require.Eventually(t, func() bool {
+				buf := executor.getPendingCommands(session)
+				buf.mu.Lock()
+				defer buf.mu.Unlock()
+
+				return len(buf.cmds) == 1
+			}, 2*time.Second, 10*time.Millisecond)
+		}).
+		Return(nil, nil)
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"configure_openai"}`}).
+		Return(appResponse(configEvent, OpenAIConfigData{APIKey: "sk-test", URL: srv.URL()}), nil)
+
+	start := StartPayload{AccountSID: "ac42", CallSID: "ca123", StreamSID: "sm123"}
+
+	err := executor.HandleCommand(session, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start})
+	require.NoError(t, err)
+
+	defer executor.Disconnect(session) // nolint:errcheck
+
+	ai, err := srv.NextConn(2 * time.Second)
+	require.NoError(t, err)
+
+	// The agent says the greeting (it's not passed to the TTS backend)
+	msg, err := ai.WaitFor("response.create", 2*time.Second)
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), "Hi, I'm Alloy")
+
+	select {
+	case text := <-synth.texts:
+		t.Fatalf("unexpected synthesis: %s", text)
+	default:
+	}
+}
@@ -1,9 +1,50 @@
 package twilio
 
+import (
+	"time"
+
+	"github.com/palkan/twilio-ai-cable/pkg/recording"
+)
+
 type Config struct {
 	AccountSID string
+	// Auth token to validate request signatures
+	AuthToken string
+	// Reject connections without a valid X-Twilio-Signature header
+	ValidateSignature bool
+	// Public base URL of the server (e.g., wss://cable.example.com) used to validate signatures;
+	// inferred from the request if empty
+	PublicURL string
+	// Record calls (could be overridden per call via the configure_openai response)
+	Record bool
+	// Where to store call recordings
+	RecordingsDir string
+	// Recording mode: "mixed" (mono) or "split" (stereo, caller on the left, bot on the right)
+	RecordingMode string
+	// Call limits (disabled by default)
+	Limits Limits
+	// Instructions for the agent to say before hanging up when a limit is reached
+	// (could be overridden per call via the configure_openai response)
+	TimeoutPrompt string
+	// How long to wait for the closing prompt to be played
+	TimeoutPromptWait time.Duration
+	// DTMF key to end the caller's turn when turn detection is disabled, e.g., "#"
+	// (could be overridden per call via the configure_openai response)
+	TurnKey string
+	// Report token usage to the app every N responses (0 means only when the call ends)
+	UsageReportInterval int
+	// How long to wait for the app to handle the agent's events (transcripts, function calls); 0 means no limit
+	RPCTimeout time.Duration
+	// What to do when a function call RPC fails or times out (RPCFailureError or RPCFailureIgnore)
+	RPCFailurePolicy string
 }
 
 func NewConfig() *Config {
-	return &Config{}
+	return &Config{
+		RecordingsDir:     "recordings",
+		RecordingMode:     recording.ModeMixed,
+		TimeoutPromptWait: 10 * time.Second,
+		RPCTimeout:        10 * time.Second,
+		RPCFailurePolicy:  RPCFailureError,
+	}
 }
@@ -140,6 +140,34 @@ func TestEncoderDecode(t *testing.T) {
 		assert.Equal(t, start, actual.Data)
 	})
 
+	t.Run("start with media format and custom parameters", func(t *testing.T) {
+		msg := []byte(`{
+			"event": "start",
+			"sequenceNumber": "1",
+			"start": {
+				"accountSid": "ac2021",
+				"streamSid": "tw2021",
+				"callSid": "ca2021",
+				"tracks": ["inbound"],
+				"customParameters": {"FirstName": "Jane", "RemoteParty": "Bob"},
+				"mediaFormat": {"encoding": "audio/x-mulaw", "sampleRate": 8000, "channels": 1}
+			},
+			"streamSid": "tw2021"
+		}`)
+
+		actual, err := coder.Decode(msg)
+
+		require.NoError(t, err)
+
+		start := actual.Data.(StartPayload)
+
+		assert.Equal(t, "ca2021", start.CallSID)
+		assert.Equal(t, []string{"inbound"}, start.Tracks)
+		assert.Equal(t, map[string]string{"FirstName": "Jane", "RemoteParty": "Bob"}, start.CustomParameters)
+		assert.Equal(t, &MediaFormat{Encoding: MulawEncoding, SampleRate: 8000, Channels: 1}, start.MediaFormat)
+		assert.True(t, start.MediaFormat.Supported())
+	})
+
 	t.Run("stop", func(t *testing.T) {
 		stop := StopPayload{StreamSID: "tw2021", AccountSID: "ac2021"}
 		msg := toJSON(StopMessage{
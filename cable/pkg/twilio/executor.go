@@ -6,19 +6,34 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/metrics"
 	"github.com/anycable/anycable-go/node"
 	"github.com/anycable/anycable-go/utils"
 	"github.com/anycable/anycable-go/ws"
 	"github.com/joomcode/errorx"
 
 	"github.com/palkan/twilio-ai-cable/pkg/agent"
+	"github.com/palkan/twilio-ai-cable/pkg/recording"
+	"github.com/palkan/twilio-ai-cable/pkg/tenant"
+	"github.com/palkan/twilio-ai-cable/pkg/tts"
 )
 
 const channelName = "Twilio::MediaStreamChannel"
 const responseState = "anycable_response"
 
+// Playback item ID for the clips played on the app's request
+const clipItemID = "clip"
+
+// Instructions to make the agent pronounce the app's text as is
+const sayInstructions = "Say the following text to the caller exactly as written, without adding anything: %s"
+
+const synthesizeTimeout = 10 * time.Second
+
 type AppResponse struct {
 	Event string          `json:"event"`
 	Data  json.RawMessage `json:"data"`
@@ -26,14 +41,58 @@ type AppResponse struct {
 
 // Handling Twilio events and transforming them into Action Cable commands
 type Executor struct {
-	node node.AppNode
-	conf *Config
+	node        node.AppNode
+	conf        *Config
+	metrics     metrics.Instrumenter
+	synth       tts.Synthesizer
+	tenants     tenant.Registry
+	callChannel bool
+
+	// Guards the lazy initialization of the sessions' RPC locks
+	mu sync.Mutex
+}
+
+var _ CommandExecutor = (*Executor)(nil)
+
+type ExecutorOption = func(*Executor)
+
+// WithInstrumenter enables agent usage metrics (see RegisterMetrics)
+func WithInstrumenter(m metrics.Instrumenter) ExecutorOption {
+	return func(ex *Executor) {
+		ex.metrics = m
+	}
 }
 
-var _ node.Executor = (*Executor)(nil)
+// WithCallChannel makes sessions subscribe to the CallChannel to receive app commands via broadcasts
+// (the channel must be routed to the CallController)
+func WithCallChannel() ExecutorOption {
+	return func(ex *Executor) {
+		ex.callChannel = true
+	}
+}
 
-func NewExecutor(node node.AppNode, c *Config) *Executor {
-	return &Executor{node: node, conf: c}
+// WithSynthesizer enables speaking the app's phrases (openai.say) when there is no agent in the call
+func WithSynthesizer(synth tts.Synthesizer) ExecutorOption {
+	return func(ex *Executor) {
+		ex.synth = synth
+	}
+}
+
+// WithTenants makes streams resolve tenants by account SIDs (streams of unknown or disabled tenants are rejected)
+func WithTenants(r tenant.Registry) ExecutorOption {
+	return func(ex *Executor) {
+		ex.tenants = r
+	}
+}
+
+func NewExecutor(node node.AppNode, c *Config, opts ...ExecutorOption) *Executor {
+	ex := &Executor{node: node, conf: c}
+
+	for _, opt := range opts {
+		opt(ex)
+	}
+
+	return ex
 }
 
 func (ex *Executor) HandleCommand(s *node.Session, msg *common.Message) error {
@@ -69,11 +128,32 @@ func (ex *Executor) HandleCommand(s *node.Session, msg *common.Message) error {
 
 		// Check if account SID matches and reject the connection if not
 		if ex.conf.AccountSID != "" && ex.conf.AccountSID != start.AccountSID {
-			s.Log.Debug("unauthenticated stream", "account_sid", start.AccountSID[0:5]+"***")
+			s.Log.Debug("unauthenticated stream", "account_sid", maskSID(start.AccountSID))
 			s.Disconnect("Auth Failed", ws.CloseNormalClosure)
 			return nil
 		}
 
+		var tn *tenant.Tenant
+
+		if ex.tenants != nil {
+			tn = ex.resolveTenant(s, start.AccountSID)
+
+			if tn == nil {
+				s.Disconnect("Auth Failed", ws.CloseNormalClosure)
+				return nil
+			}
+
+			s.Log = s.Log.With("tenant", tn.ID)
+			s.WriteInternalState("tenant", tn)
+		}
+
+		// The agent works with 8kHz μ-law only
+		if !start.MediaFormat.Supported() {
+			s.Log.Warn("unsupported media format", "format", *start.MediaFormat)
+			s.Disconnect("Unsupported Media Format", ws.CloseNormalClosure)
+			return nil
+		}
+
 		// Mark as authenticated and store the identifiers
 		callSid := start.CallSID
 		streamSid := start.StreamSID
@@ -82,24 +162,66 @@ func (ex *Executor) HandleCommand(s *node.Session, msg *common.Message) error {
 		s.WriteInternalState("callSid", callSid)
 		s.WriteInternalState("streamSid", streamSid)
 
-		identifiers := string(utils.ToJSON(map[string]string{"call_sid": callSid, "stream_sid": streamSid}))
+		ids := map[string]string{"call_sid": callSid, "stream_sid": streamSid}
+
+		if tn != nil {
+			ids["tenant"] = tn.ID
+		}
+
+		identifiers := string(utils.ToJSON(ids))
 
 		ex.node.Authenticated(s, identifiers)
 
+		// Speech is synthesized in the background (one phrase at a time, in order),
+		// so TTS requests don't block the media stream
+		if ex.synth != nil {
+			s.WriteInternalState("speechQueue", NewRPCQueue(0, s.Log))
+		}
+
+		// Commands requiring the agent (e.g., the greeting transmitted on subscribe) are held until the agent is initialized
+		s.WriteInternalState("pendingCommands", &commandBuffer{})
+
 		// Now, subscribe to the channel to initialize the session
 		identifier := channelId(s)
-		_, err := ex.node.Subscribe(s, &common.Message{Identifier: identifier, Command: "subscribe"})
+
+		var err error
+
+		ex.withRPCLock(s, func() {
+			_, err = ex.node.Subscribe(s, &common.Message{Identifier: identifier, Command: "subscribe"})
+		})
 
 		if err != nil {
 			return err
 		}
 
-		err = ex.initAgent(s)
+		if ex.callChannel {
+			ex.withRPCLock(s, func() {
+				_, err = ex.node.Subscribe(s, &common.Message{Identifier: callChannelId(), Command: "subscribe"})
+			})
+
+			if err != nil {
+				s.Log.Error("failed to subscribe to call commands", "error", err)
+			}
+		}
+
+		err = ex.initAgent(s, start.CustomParameters)
 
 		if err != nil {
 			return err
 		}
 
+		ex.flushPendingCommands(s)
+
+		if _, ok := s.ReadInternalState("recorder"); !ok && ex.conf.Record {
+			s.WriteInternalState("recorder", recording.NewRecorder())
+		}
+
+		if ex.conf.Limits.Enabled() {
+			wd := NewWatchdog(ex.conf.Limits, func(reason string) { ex.handleTimeout(s, reason) })
+			s.WriteInternalState("watchdog", wd)
+			wd.Start()
+		}
+
 		return nil
 	}
 
@@ -111,72 +233,210 @@ func (ex *Executor) HandleCommand(s *node.Session, msg *common.Message) error {
 			return nil
 		}
 
-		ai := ex.getAI(s)
-
-		if ai == nil {
-			return nil
-		}
-
 		audioBytes, err := base64.StdEncoding.DecodeString(twilioMsg.Payload)
 
 		if err != nil {
 			return err
 		}
 
+		if rec := ex.getRecorder(s); rec != nil {
+			rec.WriteInbound(audioBytes)
+		}
+
+		if wd := ex.getWatchdog(s); wd != nil && hasVoice(audioBytes) {
+			wd.Speech()
+		}
+
+		ai := ex.getAI(s)
+
+		if ai == nil {
+			return nil
+		}
+
 		err = ai.EnqueueAudio(audioBytes)
 
 		return err
 	}
 
 	if msg.Command == MarkEvent {
-		s.Log.Debug("mark received", "msg", msg.Data)
-		// TODO: Here we can track which media messages has been processed,
-		// so we can implement some clearing logic.
-		// See https://www.twilio.com/docs/voice/media-streams/websocket-messages#send-a-clear-message
+		mark, ok := msg.Data.(MarkPayload)
+
+		if !ok {
+			return nil
+		}
+
+		s.Log.Debug("mark received", "name", mark.Name)
+
+		ex.getPlayback(s).Ack(mark.Name)
+
 		return nil
 	}
 
 	if msg.Command == DTMFEvent {
 		// DTMF is sent over RPC
 		dtfm := msg.Data.(DTMFPayload)
-		_, err := ex.performRPC(s, "handle_dtmf", map[string]string{"digit": dtfm.Digit})
 
-		if err != nil {
-			return err
+		if wd := ex.getWatchdog(s); wd != nil {
+			wd.Activity()
 		}
 
-		// TODO: handle response (e.g., send some command to the AI agent)
+		// Push-to-talk: the caller ends the turn by pressing the turn key
+		if ai := ex.getAI(s); ai != nil && dtfm.Digit == ex.getTurnKey(s) {
+			s.Log.Debug("turn key pressed")
 
-		return nil
+			ex.interrupt(s, ai)
+			ai.CommitInput()
+
+			return nil
+		}
+
+		// The app responds with commands (e.g., openai.say or call.hangup), they're handled by performRPC
+		_, err := ex.performRPC(s, "handle_dtmf", map[string]interface{}{"digit": dtfm.Digit})
+
+		return err
 	}
 
 	return fmt.Errorf("Unknown command: %s", msg.Command)
 }
 
 func (ex *Executor) Disconnect(s *node.Session) error {
+	if wd := ex.getWatchdog(s); wd != nil {
+		wd.Stop()
+	}
+
 	ai := ex.getAI(s)
 
 	if ai != nil {
 		ai.Close()
 	}
 
-	return ex.node.Disconnect(s)
+	// Pending RPCs (e.g., the last transcripts) are performed before the final usage report and the disconnect
+	if queue := ex.getRPCQueue(s); queue != nil {
+		queue.Close()
+
+		if !queue.Wait(ex.conf.RPCTimeout) {
+			s.Log.Warn("pending rpcs haven't been performed in time", "timeout", ex.conf.RPCTimeout)
+		}
+	}
+
+	if ai != nil {
+		ex.reportUsage(s, ai.Usage(), true)
+	}
+
+	if queue := ex.getSpeechQueue(s); queue != nil {
+		queue.Close()
+	}
+
+	if rec := ex.getRecorder(s); rec != nil {
+		ex.saveRecording(s, rec)
+	}
+
+	// Unsubscribe explicitly, so the app doesn't receive the server-side channel on disconnect
+	if ex.callChannel {
+		var err error
+
+		ex.withRPCLock(s, func() {
+			_, err = ex.node.Unsubscribe(s, &common.Message{Identifier: callChannelId(), Command: "unsubscribe"})
+		})
+
+		if err != nil {
+			s.Log.Debug("failed to unsubscribe from call commands", "error", err)
+		}
+	}
+
+	var err error
+
+	ex.withRPCLock(s, func() {
+		err = ex.node.Disconnect(s)
+	})
+
+	return err
+}
+
+// resolveTenant returns the tenant for the account SID or nil if the stream must be rejected
+func (ex *Executor) resolveTenant(s *node.Session, accountSID string) *tenant.Tenant {
+	tn, err := ex.tenants.Lookup(accountSID)
+
+	if err != nil {
+		if errors.Is(err, tenant.ErrNotFound) {
+			s.Log.Debug("unknown tenant", "account_sid", maskSID(accountSID))
+		} else {
+			s.Log.Error("failed to resolve tenant", "account_sid", maskSID(accountSID), "error", err)
+		}
+
+		return nil
+	}
+
+	if tn.Disabled {
+		s.Log.Debug("tenant is disabled", "tenant", tn.ID)
+		return nil
+	}
+
+	if ex.conf.ValidateSignature && !ex.validSignature(s, tn) {
+		s.Log.Debug("invalid Twilio signature", "tenant", tn.ID)
+		return nil
+	}
+
+	return tn
+}
+
+// validSignature checks the upgrade request signature (see SignatureValidator.Deferred) with the tenant's auth token
+func (ex *Executor) validSignature(s *node.Session, tn *tenant.Tenant) bool {
+	token := tn.AuthToken
+
+	if token == "" {
+		token = ex.conf.AuthToken
+	}
+
+	env := s.GetEnv()
+
+	if token == "" || env == nil || env.Headers == nil {
+		return false
+	}
+
+	headers := *env.Headers
+
+	return ValidateSignature(token, headers[strings.ToLower(StreamURLHeader)], headers[strings.ToLower(SignatureHeader)])
 }
 
 // Supported RPC response types
 const configEvent = "openai.configuration"
+const functionCallResultEvent = "openai.function_call_result"
 
 type OpenAIConfigData struct {
+	// Realtime provider name, OpenAI is used by default
+	Provider string `json:"provider,omitempty"`
+	// Custom provider URL (e.g., a proxy or a self-hosted model)
+	URL    string `json:"url,omitempty"`
 	APIKey string `json:"api_key"`
 	Model  string `json:"model,omitempty"`
 	Voice  string `json:"voice,omitempty"`
 	Prompt string `json:"prompt,omitempty"`
 	Tools  string `json:"tools,omitempty"`
+	// Whether to record the call (overrides the server-wide setting)
+	Record *bool `json:"record,omitempty"`
+	// Closing prompt instructions used when a call limit is reached (overrides the server-wide setting)
+	TimeoutPrompt string `json:"timeout_prompt,omitempty"`
+	// Make the agent greet the caller right away (instead of waiting for the caller to speak)
+	SpeakFirst bool `json:"speak_first,omitempty"`
+	// Instructions for the greeting (implies speak_first)
+	Greeting string `json:"greeting,omitempty"`
+	// Turn detection (VAD) settings, e.g., {"threshold":0.7,"silence_duration_ms":800} or {"mode":"manual"}
+	TurnDetection *agent.TurnDetection `json:"turn_detection,omitempty"`
+	// DTMF key to end the caller's turn when turn detection is disabled (overrides the server-wide setting)
+	TurnKey string `json:"turn_key,omitempty"`
 }
 
-func (ex *Executor) initAgent(s *node.Session) error {
+func (ex *Executor) initAgent(s *node.Session, params map[string]string) error {
+	var payload map[string]interface{}
+
+	// Pass TwiML <Parameter>-s to the app, so it can configure the agent for the particular call
+	if len(params) > 0 {
+		payload = map[string]interface{}{"custom_parameters": params}
+	}
+
 	// Retrieve AI configuration from the main app
-	res, err := ex.performRPC(s, "configure_openai", nil)
+	res, err := ex.performRPC(s, "configure_openai", payload)
 
 	if err != nil {
 		return err
@@ -200,7 +460,23 @@ func (ex *Executor) initAgent(s *node.Session) error {
 		return errorx.Decorate(err, "failed to parse OpenAI config from RPC")
 	}
 
-	conf := agent.NewConfig(data.APIKey)
+	conf := agent.NewConfig("")
+
+	if tn := ex.getTenant(s); tn != nil {
+		tn.Agent.Apply(conf)
+	}
+
+	if data.APIKey != "" {
+		conf.Key = data.APIKey
+	}
+
+	if data.Provider != "" {
+		conf.Provider = data.Provider
+	}
+
+	if data.URL != "" {
+		conf.URL = data.URL
+	}
 
 	if data.Model != "" {
 		conf.Model = data.Model
@@ -218,50 +494,420 @@ func (ex *Executor) initAgent(s *node.Session) error {
 		conf.Tools = json.RawMessage(data.Tools)
 	}
 
-	agent := agent.NewAgent(conf, s.Log)
+	if data.TurnDetection != nil {
+		if err := data.TurnDetection.Validate(); err != nil {
+			s.Log.Error("invalid turn detection settings, ignoring", "error", err)
+		} else {
+			conf.TurnDetection = data.TurnDetection
+		}
+	}
+
+	if data.SpeakFirst || data.Greeting != "" {
+		conf.SpeakFirst = true
+		conf.Greeting = data.Greeting
+	}
 
-	agent.HandleTranscript(func(role string, text string, id string) {
-		_, err := ex.performRPC(s, "handle_transcript", map[string]string{"role": role, "text": text, "id": id})
+	record := ex.conf.Record
 
-		if err != nil {
-			s.Log.Error("failed to perform handle_transcript rpc", "error", err)
+	if data.Record != nil {
+		record = *data.Record
+	}
+
+	if record {
+		s.WriteInternalState("recorder", recording.NewRecorder())
+	} else {
+		// Mark recording as explicitly disabled
+		s.WriteInternalState("recorder", (*recording.Recorder)(nil))
+	}
+
+	if data.TimeoutPrompt != "" {
+		s.WriteInternalState("timeoutPrompt", data.TimeoutPrompt)
+	}
+
+	// The turn key is only used when turns are not detected by the agent
+	if conf.TurnDetection.Manual() {
+		turnKey := ex.conf.TurnKey
+
+		if data.TurnKey != "" {
+			turnKey = data.TurnKey
+		}
+
+		if turnKey != "" {
+			s.WriteInternalState("turnKey", turnKey)
 		}
+	}
+
+	ai := agent.NewAgent(conf, s.Log)
+
+	s.WriteInternalState("playback", NewPlayback())
+
+	// Agent callbacks are called from the agent's reader, so we perform RPCs in the background
+	queue := NewRPCQueue(ex.conf.RPCTimeout, s.Log)
+	s.WriteInternalState("rpcQueue", queue)
+
+	ai.HandleTranscript(func(role string, text string, id string) {
+		queue.Enqueue("handle_transcript", func() {
+			_, err := ex.performRPC(s, "handle_transcript", map[string]interface{}{"role": role, "text": text, "id": id})
+
+			if err != nil {
+				s.Log.Error("failed to perform handle_transcript rpc", "error", err)
+			}
+		}, nil)
 	})
 
-	agent.HandleAudio(func(encodedAudio string, id string) {
-		var streamSid string
-		if val, ok := s.ReadInternalState("streamSid"); ok {
-			streamSid = val.(string)
-		} else {
-			return
+	ai.HandleAudio(func(encodedAudio string, id string) {
+		ex.sendAudio(s, id, encodedAudio)
+	})
+
+	// Barge-in: stop the playback and the current response as soon as the caller starts speaking
+	ai.HandleSpeechStarted(func(id string) {
+		s.Log.Debug("caller started speaking, clearing playback", "id", id)
+
+		if wd := ex.getWatchdog(s); wd != nil {
+			wd.Speech()
 		}
 
-		s.Send(&common.Reply{Type: MediaEvent, Message: MediaPayload{Payload: encodedAudio}, Identifier: streamSid})
-		s.Send(&common.Reply{Type: MarkEvent, Message: MarkPayload{Name: `ai-delta-` + id}, Identifier: streamSid})
+		ex.interrupt(s, ai)
 	})
 
-	agent.HandleFunctionCall(func(name string, args string, id string) {
-		res, err := ex.performRPC(s, "handle_function_call", map[string]string{"name": name, "arguments": args})
+	ai.HandleFunctionCall(func(name string, args string, id string) {
+		var once sync.Once
 
-		if err != nil {
-			s.Log.Error("failed to perform handle_function_call rpc", "error", err)
+		// The call is settled only once: the late result of the timed out RPC is ignored
+		settle := func(result string) {
+			once.Do(func() {
+				if result != "" {
+					ai.HandleFunctionCallResult(id, result)
+				}
+			})
+		}
+
+		queue.Enqueue("handle_function_call", func() {
+			res, err := ex.performRPC(s, "handle_function_call", map[string]interface{}{"name": name, "arguments": args})
+
+			if err != nil {
+				s.Log.Error("failed to perform handle_function_call rpc", "error", err)
+				settle(ex.functionCallFailure("The request to the app failed"))
+				return
+			}
+
+			if res != nil && res.Event == functionCallResultEvent {
+				settle(string(res.Data))
+			}
+		}, func() {
+			settle(ex.functionCallFailure("The request to the app timed out"))
+		})
+	})
+
+	ai.HandleUsage(func(usage *agent.Usage, totals agent.UsageTotals) {
+		if ex.metrics != nil {
+			trackUsage(ex.metrics, usage)
 		}
 
-		if res != nil && res.Event == "openai.function_call_result" {
-			agent.HandleFunctionCallResult(id, string(res.Data))
+		if n := ex.conf.UsageReportInterval; n > 0 && totals.Responses%n == 0 {
+			queue.Enqueue("handle_usage", func() { ex.reportUsage(s, totals, false) }, nil)
 		}
 	})
 
-	err = agent.KickOff(context.Background())
+	err = ai.KickOff(context.Background())
 	if err != nil {
 		return err
 	}
 
-	s.WriteInternalState("agent", agent)
+	s.WriteInternalState("agent", ai)
+
+	go ex.watchAgent(s, ai)
 
 	return nil
 }
 
+// watchAgent tears down the call if the agent fails (e.g., the connection can't be restored)
+func (ex *Executor) watchAgent(s *node.Session, ai *agent.Agent) {
+	<-ai.Done()
+
+	if err := ai.Err(); err != nil {
+		s.Log.Error("agent failed, closing the stream", "error", err)
+		s.Disconnect("Agent Failed", ws.CloseNormalClosure)
+	}
+}
+
+// sendAudio sends the Base64-encoded μ-law audio to the caller followed by a mark to track the playback
+func (ex *Executor) sendAudio(s *node.Session, itemID string, encodedAudio string) {
+	var streamSid string
+	if val, ok := s.ReadInternalState("streamSid"); ok {
+		streamSid = val.(string)
+	} else {
+		return
+	}
+
+	mark := ex.getPlayback(s).Enqueue(itemID, decodedLen(encodedAudio))
+
+	if wd := ex.getWatchdog(s); wd != nil {
+		wd.Activity()
+	}
+
+	if rec := ex.getRecorder(s); rec != nil {
+		if audio, err := base64.StdEncoding.DecodeString(encodedAudio); err == nil {
+			rec.WriteOutbound(audio)
+		}
+	}
+
+	s.Send(&common.Reply{Type: MediaEvent, Message: MediaPayload{Payload: encodedAudio}, Identifier: streamSid})
+	s.Send(&common.Reply{Type: MarkEvent, Message: MarkPayload{Name: mark}, Identifier: streamSid})
+}
+
+// interrupt stops the playback and the agent's response (if any)
+func (ex *Executor) interrupt(s *node.Session, ai *agent.Agent) {
+	var streamSid string
+	if val, ok := s.ReadInternalState("streamSid"); ok {
+		streamSid = val.(string)
+	} else {
+		return
+	}
+
+	// Calculate the played audio before clearing: Twilio acks all the pending marks on clear
+	itemID, playedMs := ex.getPlayback(s).Interrupt()
+
+	s.Send(&common.Reply{Type: ClearEvent, Identifier: streamSid})
+
+	if rec := ex.getRecorder(s); rec != nil {
+		rec.Clear()
+	}
+
+	if ai == nil {
+		return
+	}
+
+	ai.CancelResponse()
+
+	if itemID != "" && itemID != clipItemID {
+		ai.TruncateItem(itemID, playedMs)
+	}
+}
+
+type SessionUpdateData struct {
+	Prompt string `json:"prompt,omitempty"`
+	Voice  string `json:"voice,omitempty"`
+	Tools  string `json:"tools,omitempty"`
+}
+
+type UserMessageData struct {
+	Text string `json:"text"`
+}
+
+type SayData struct {
+	Text string `json:"text"`
+	// Voice to use for TTS (the agent always speaks with the session's voice)
+	Voice string `json:"voice,omitempty"`
+}
+
+type ResponseData struct {
+	Instructions string `json:"instructions,omitempty"`
+}
+
+type PlayData struct {
+	// Base64-encoded 8kHz μ-law audio
+	Audio string `json:"audio"`
+}
+
+// HandleAppCommand handles commands sent by the app (as RPC responses or via broadcasts)
+func (ex *Executor) HandleAppCommand(s *node.Session, cmd *AppResponse) {
+	s.Log.Debug("app command received", "event", cmd.Event)
+
+	ai := ex.getAI(s)
+
+	if ai == nil && agentCommands[cmd.Event] {
+		if pending := ex.getPendingCommands(s); pending != nil {
+			if pending.Add(cmd) {
+				s.Log.Debug("agent is not initialized yet, command is held", "event", cmd.Event)
+				return
+			}
+
+			// The agent could've been initialized in the meantime
+			ai = ex.getAI(s)
+		}
+	}
+
+	switch cmd.Event {
+	case sessionUpdateEvent:
+		if ai == nil {
+			s.Log.Warn("no agent to update session")
+			return
+		}
+
+		var data SessionUpdateData
+
+		if err := json.Unmarshal(cmd.Data, &data); err != nil {
+			s.Log.Error("failed to parse session update", "error", err)
+			return
+		}
+
+		update := &agent.SessionUpdate{Prompt: data.Prompt, Voice: data.Voice}
+
+		if data.Tools != "" {
+			update.Tools = json.RawMessage(data.Tools)
+		}
+
+		ai.UpdateSession(update)
+	case userMessageEvent:
+		if ai == nil {
+			s.Log.Warn("no agent to send user message")
+			return
+		}
+
+		var data UserMessageData
+
+		if err := json.Unmarshal(cmd.Data, &data); err != nil || data.Text == "" {
+			s.Log.Error("malformed user message", "data", string(cmd.Data), "error", err)
+			return
+		}
+
+		ai.SendUserMessage(data.Text)
+	case responseEvent:
+		if ai == nil {
+			s.Log.Warn("no agent to create response")
+			return
+		}
+
+		var data ResponseData
+
+		if len(cmd.Data) > 0 {
+			if err := json.Unmarshal(cmd.Data, &data); err != nil {
+				s.Log.Error("failed to parse response command", "error", err)
+				return
+			}
+		}
+
+		ai.CreateResponse(data.Instructions)
+	case commitEvent:
+		if ai == nil {
+			s.Log.Warn("no agent to commit input")
+			return
+		}
+
+		ai.CommitInput()
+	case sayEvent:
+		var data SayData
+
+		if err := json.Unmarshal(cmd.Data, &data); err != nil || data.Text == "" {
+			s.Log.Error("malformed say command", "data", string(cmd.Data), "error", err)
+			return
+		}
+
+		ex.say(s, ai, data.Text, data.Voice)
+	case clearEvent:
+		ex.interrupt(s, ai)
+	case playEvent:
+		var data PlayData
+
+		if err := json.Unmarshal(cmd.Data, &data); err != nil || data.Audio == "" {
+			s.Log.Error("malformed play command", "error", err)
+			return
+		}
+
+		ex.sendAudio(s, clipItemID, data.Audio)
+	case hangupEvent:
+		s.Log.Info("hanging up on the app's request")
+		// Disconnect asynchronously: the command may come from a pending RPC, and disconnecting waits for them
+		go s.Disconnect("Hangup", ws.CloseNormalClosure)
+	default:
+		s.Log.Warn("unknown app command", "event", cmd.Event)
+	}
+}
+
+// flushPendingCommands handles the commands received while the agent was being initialized
+func (ex *Executor) flushPendingCommands(s *node.Session) {
+	pending := ex.getPendingCommands(s)
+
+	if pending == nil {
+		return
+	}
+
+	for _, cmd := range pending.Flush() {
+		ex.HandleAppCommand(s, cmd)
+	}
+}
+
+// say makes the agent (or the TTS backend if there is no agent) say the text as is
+func (ex *Executor) say(s *node.Session, ai *agent.Agent, text string, voice string) {
+	if ai != nil {
+		ex.interrupt(s, ai)
+		ai.CreateResponse(fmt.Sprintf(sayInstructions, text))
+		return
+	}
+
+	queue := ex.getSpeechQueue(s)
+
+	if queue == nil {
+		s.Log.Warn("no agent or TTS backend to say text", "text", text)
+		return
+	}
+
+	queue.Enqueue("synthesize", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), synthesizeTimeout)
+		defer cancel()
+
+		audio, err := ex.synth.Synthesize(ctx, text, voice)
+
+		if err != nil {
+			s.Log.Error("failed to synthesize speech", "error", err)
+			return
+		}
+
+		ex.interrupt(s, nil)
+		ex.sendAudio(s, clipItemID, base64.StdEncoding.EncodeToString(audio))
+	}, nil)
+}
+
+// functionCallFailure returns the function call result to send to the agent when the RPC fails (according to the failure policy)
+func (ex *Executor) functionCallFailure(reason string) string {
+	if ex.conf.RPCFailurePolicy != RPCFailureError {
+		return ""
+	}
+
+	return string(utils.ToJSON(map[string]string{"error": reason}))
+}
+
+// reportUsage sends the session token usage to the app
+func (ex *Executor) reportUsage(s *node.Session, totals agent.UsageTotals, final bool) {
+	if _, err := ex.performRPC(s, "handle_usage", map[string]interface{}{"usage": totals, "final": final}); err != nil {
+		s.Log.Error("failed to perform handle_usage rpc", "error", err)
+	}
+}
+
+// handleTimeout is called when a call limit is reached: it makes the agent say the closing prompt (if any),
+// notifies the app and disconnects the session
+func (ex *Executor) handleTimeout(s *node.Session, reason string) {
+	s.Log.Info("call limit reached", "reason", reason)
+
+	prompt := ex.conf.TimeoutPrompt
+
+	if val, ok := s.ReadInternalState("timeoutPrompt"); ok {
+		prompt = val.(string)
+	}
+
+	ai := ex.getAI(s)
+	playback := ex.getPlayback(s)
+	enqueued := playback.Enqueued()
+
+	if ai != nil && prompt != "" {
+		ai.CancelResponse()
+		ai.CreateResponse(prompt)
+	}
+
+	if _, err := ex.performRPC(s, "handle_timeout", map[string]interface{}{"reason": reason}); err != nil {
+		s.Log.Error("failed to perform handle_timeout rpc", "error", err)
+	}
+
+	if ai != nil && prompt != "" {
+		if !playback.WaitPlayed(enqueued, ex.conf.TimeoutPromptWait) {
+			s.Log.Debug("closing prompt hasn't been played in time")
+		}
+	}
+
+	s.Disconnect("Timeout", ws.CloseNormalClosure)
+}
+
 func (ex *Executor) getAI(s *node.Session) *agent.Agent {
 	var ai *agent.Agent
 
@@ -272,9 +918,94 @@ func (ex *Executor) getAI(s *node.Session) *agent.Agent {
 	return ai
 }
 
-func (ex *Executor) performRPC(s *node.Session, action string, data map[string]string) (*AppResponse, error) {
+func (ex *Executor) getPlayback(s *node.Session) *Playback {
+	if rawPlayback, ok := s.ReadInternalState("playback"); ok {
+		return rawPlayback.(*Playback)
+	}
+
+	// Playback is only tracked for agent-driven sessions,
+	// return a throwaway tracker for others
+	return NewPlayback()
+}
+
+func (ex *Executor) getTenant(s *node.Session) *tenant.Tenant {
+	if rawTn, ok := s.ReadInternalState("tenant"); ok {
+		return rawTn.(*tenant.Tenant)
+	}
+
+	return nil
+}
+
+func (ex *Executor) getTurnKey(s *node.Session) string {
+	if rawKey, ok := s.ReadInternalState("turnKey"); ok {
+		return rawKey.(string)
+	}
+
+	return ""
+}
+
+func (ex *Executor) getRPCQueue(s *node.Session) *RPCQueue {
+	if rawQueue, ok := s.ReadInternalState("rpcQueue"); ok {
+		return rawQueue.(*RPCQueue)
+	}
+
+	return nil
+}
+
+func (ex *Executor) getPendingCommands(s *node.Session) *commandBuffer {
+	if rawBuf, ok := s.ReadInternalState("pendingCommands"); ok {
+		return rawBuf.(*commandBuffer)
+	}
+
+	return nil
+}
+
+func (ex *Executor) getSpeechQueue(s *node.Session) *RPCQueue {
+	if rawQueue, ok := s.ReadInternalState("speechQueue"); ok {
+		return rawQueue.(*RPCQueue)
+	}
+
+	return nil
+}
+
+func (ex *Executor) getWatchdog(s *node.Session) *Watchdog {
+	if rawWd, ok := s.ReadInternalState("watchdog"); ok {
+		return rawWd.(*Watchdog)
+	}
+
+	return nil
+}
+
+func (ex *Executor) getRecorder(s *node.Session) *recording.Recorder {
+	var rec *recording.Recorder
+
+	if rawRec, ok := s.ReadInternalState("recorder"); ok {
+		rec = rawRec.(*recording.Recorder)
+	}
+
+	return rec
+}
+
+func (ex *Executor) saveRecording(s *node.Session, rec *recording.Recorder) {
+	name := s.GetID()
+
+	if val, ok := s.ReadInternalState("callSid"); ok && val.(string) != "" {
+		name = val.(string)
+	}
+
+	path, err := rec.Save(ex.conf.RecordingsDir, name, ex.conf.RecordingMode)
+
+	if err != nil {
+		s.Log.Error("failed to save recording", "error", err)
+		return
+	}
+
+	s.Log.Info("recording saved", "path", path)
+}
+
+func (ex *Executor) performRPC(s *node.Session, action string, data map[string]interface{}) (*AppResponse, error) {
 	if data == nil {
-		data = make(map[string]string)
+		data = make(map[string]interface{})
 	}
 
 	data["action"] = action
@@ -283,10 +1014,21 @@ func (ex *Executor) performRPC(s *node.Session, action string, data map[string]s
 
 	identifier := channelId(s)
 
-	res, err := ex.node.Perform(s, &common.Message{
-		Identifier: identifier,
-		Command:    "message",
-		Data:       string(payload),
+	var res *common.CommandResult
+	var err error
+
+	ex.withRPCLock(s, func() {
+		res, err = ex.node.Perform(s, &common.Message{
+			Identifier: identifier,
+			Command:    "message",
+			Data:       string(payload),
+		})
+
+		// Cleanup the channel state — we don't need to carry this state around
+		// (empty values are removed from the state, which is modified under the session's lock)
+		if err == nil && res != nil && res.IState[responseState] != "" {
+			s.MergeEnv(&common.SessionEnv{ChannelStates: &map[string]map[string]string{identifier: {responseState: ""}}})
+		}
 	})
 
 	if err != nil {
@@ -303,19 +1045,49 @@ func (ex *Executor) performRPC(s *node.Session, action string, data map[string]s
 		return nil, nil
 	}
 
-	// Cleanup the channel state — we don't need to carry this state around
-	st := (*s.GetEnv().ChannelStates)[identifier]
-	delete(st, responseState)
-
 	var rpcRes AppResponse
 	err = json.Unmarshal([]byte(rawRes), &rpcRes)
 	if err != nil {
 		return nil, errorx.Decorate(err, "failed to parse RPC response")
 	}
 
+	if isAppCommand(rpcRes.Event) {
+		ex.HandleAppCommand(s, &rpcRes)
+	}
+
 	return &rpcRes, nil
 }
 
+// withRPCLock performs the session's RPC calls one at a time.
+// RPCs are performed from different goroutines (the session's reader, the RPC queue, the watchdog, etc.),
+// but the node doesn't synchronize concurrent calls modifying the session's state.
+func (ex *Executor) withRPCLock(s *node.Session, fn func()) {
+	mu := ex.getRPCLock(s)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	fn()
+}
+
+func (ex *Executor) getRPCLock(s *node.Session) *sync.Mutex {
+	ex.mu.Lock()
+	defer ex.mu.Unlock()
+
+	if rawLock, ok := s.ReadInternalState("rpcLock"); ok {
+		return rawLock.(*sync.Mutex)
+	}
+
+	mu := &sync.Mutex{}
+	s.WriteInternalState("rpcLock", mu)
+
+	return mu
+}
+
+func maskSID(sid string) string {
+	return sid[0:min(5, len(sid))] + "***"
+}
+
 func channelId(s *node.Session) string {
 	msg := struct {
 		Channel string `json:"channel"`
@@ -323,3 +1095,10 @@ func channelId(s *node.Session) string {
 
 	return string(utils.ToJSON(msg))
 }
+
+// decodedLen returns the number of bytes in the Base64-encoded string
+func decodedLen(encoded string) int {
+	n := base64.StdEncoding.DecodedLen(len(encoded))
+
+	return n - strings.Count(encoded[max(0, len(encoded)-2):], "=")
+}
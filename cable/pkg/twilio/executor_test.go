@@ -4,16 +4,24 @@ import (
 	"encoding/json"
 	"log/slog"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/anycable/anycable-go/common"
 	"github.com/anycable/anycable-go/metrics"
 	"github.com/anycable/anycable-go/mocks"
 	"github.com/anycable/anycable-go/node"
 	"github.com/anycable/anycable-go/node_mocks"
+	"github.com/anycable/anycable-go/ws"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+
+	"github.com/palkan/twilio-ai-cable/internal/fake_openai"
+	"github.com/palkan/twilio-ai-cable/pkg/agent"
+	"github.com/palkan/twilio-ai-cable/pkg/tenant"
 )
 
 func TestHandleCommandConnected(t *testing.T) {
@@ -96,6 +104,644 @@ func TestHandleCommandStart(t *testing.T) {
 
 		require.NoError(t, err)
 	})
+
+	t.Run("passes custom parameters to configure_openai", func(t *testing.T) {
+		conn := mocks.NewMockConnection()
+		session := buildSession(conn, n, executor, true)
+
+		start := StartPayload{AccountSID: "ac42", CallSID: "ca123", StreamSID: "sm123", CustomParameters: map[string]string{"FirstName": "Jane"}}
+
+		app.On("Authenticated", session, mock.Anything)
+		app.
+			On("Subscribe", session, &common.Message{Identifier: channelId(session), Command: "subscribe"}).
+			Return(nil, nil)
+		app.
+			On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"configure_openai","custom_parameters":{"FirstName":"Jane"}}`}).
+			Return(nil, nil)
+
+		err := executor.HandleCommand(session, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start})
+
+		require.NoError(t, err)
+		app.AssertCalled(t, "Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"configure_openai","custom_parameters":{"FirstName":"Jane"}}`})
+	})
+
+	t.Run("rejects unsupported media format", func(t *testing.T) {
+		conn := mocks.NewMockConnection()
+		session := buildSession(conn, n, executor, true)
+
+		start := StartPayload{AccountSID: "ac42", CallSID: "ca123", StreamSID: "sm123", MediaFormat: &MediaFormat{Encoding: "audio/x-l16", SampleRate: 16000, Channels: 1}}
+
+		app.On("Disconnect", session).Return(nil)
+
+		err := executor.HandleCommand(session, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start})
+
+		require.NoError(t, err)
+		assert.True(t, session.IsClosed())
+		app.AssertNotCalled(t, "Authenticated", session, mock.Anything)
+	})
+}
+
+func TestHandleCommandStartWithTenants(t *testing.T) {
+	app := &node_mocks.AppNode{}
+	n := NewMockNode()
+	c := NewConfig()
+
+	registry, err := tenant.ParseFile(`
+tenants:
+  - id: acme
+    account_sid: ac42
+    auth_token: acme-secret
+  - id: globex
+    account_sid: ac43
+    disabled: true
+`)
+	require.NoError(t, err)
+
+	executor := NewExecutor(app, c, WithTenants(registry))
+
+	t.Run("attaches the tenant to identifiers", func(t *testing.T) {
+		conn := mocks.NewMockConnection()
+		session := buildSession(conn, n, executor, true)
+
+		start := StartPayload{AccountSID: "ac42", CallSID: "ca123", StreamSID: "sm123"}
+
+		app.On("Authenticated", session, `{"call_sid":"ca123","stream_sid":"sm123","tenant":"acme"}`)
+		app.
+			On("Subscribe", session, &common.Message{Identifier: channelId(session), Command: "subscribe"}).
+			Return(nil, nil)
+		app.
+			On("Perform", session, performAction("configure_openai")).
+			Return(nil, nil)
+
+		err := executor.HandleCommand(session, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start})
+
+		require.NoError(t, err)
+		app.AssertCalled(t, "Authenticated", session, `{"call_sid":"ca123","stream_sid":"sm123","tenant":"acme"}`)
+		assert.Equal(t, "acme", executor.getTenant(session).ID)
+	})
+
+	for name, accountSID := range map[string]string{"rejects unknown tenants": "ac00", "rejects disabled tenants": "ac43"} {
+		t.Run(name, func(t *testing.T) {
+			conn := mocks.NewMockConnection()
+			session := buildSession(conn, n, executor, true)
+
+			start := StartPayload{AccountSID: accountSID, CallSID: "ca123", StreamSID: "sm123"}
+
+			app.On("Disconnect", session).Return(nil)
+
+			err := executor.HandleCommand(session, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start})
+
+			require.NoError(t, err)
+			assert.True(t, session.IsClosed())
+			app.AssertNotCalled(t, "Authenticated", session, mock.Anything)
+		})
+	}
+
+	t.Run("validates signatures with the tenant's auth token", func(t *testing.T) {
+		c := NewConfig()
+		c.ValidateSignature = true
+
+		executor := NewExecutor(app, c, WithTenants(registry))
+
+		buildSignedSession := func(token string) *node.Session {
+			headers := map[string]string{
+				"x-twilio-stream-url": "wss://cable.example.com/twilio",
+				"x-twilio-signature":  ComputeSignature(token, "wss://cable.example.com/twilio", nil),
+			}
+
+			s := node.NewSession(n, mocks.NewMockConnection(), "ws://anycable.io/twilio", &headers, "signed-"+token, node.WithEncoder(Encoder{}), node.WithExecutor(executor))
+			s.Connected = true
+			s.Log = slog.With("context", "test")
+			return s
+		}
+
+		start := StartPayload{AccountSID: "ac42", CallSID: "ca123", StreamSID: "sm123"}
+
+		forged := buildSignedSession("other-secret")
+		app.On("Disconnect", forged).Return(nil)
+
+		require.NoError(t, executor.HandleCommand(forged, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start}))
+		assert.True(t, forged.IsClosed())
+		app.AssertNotCalled(t, "Authenticated", forged, mock.Anything)
+
+		signed := buildSignedSession("acme-secret")
+		app.On("Authenticated", signed, mock.Anything)
+		app.
+			On("Subscribe", signed, &common.Message{Identifier: channelId(signed), Command: "subscribe"}).
+			Return(nil, nil)
+		app.
+			On("Perform", signed, performAction("configure_openai")).
+			Return(nil, nil)
+
+		require.NoError(t, executor.HandleCommand(signed, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start}))
+		assert.False(t, signed.IsClosed())
+		app.AssertCalled(t, "Authenticated", signed, mock.Anything)
+	})
+}
+
+func TestMediaFormatSupported(t *testing.T) {
+	var missing *MediaFormat
+
+	assert.True(t, missing.Supported())
+	assert.True(t, (&MediaFormat{Encoding: MulawEncoding, SampleRate: 8000, Channels: 1}).Supported())
+	assert.False(t, (&MediaFormat{Encoding: MulawEncoding, SampleRate: 16000, Channels: 1}).Supported())
+	assert.False(t, (&MediaFormat{Encoding: "audio/x-l16", SampleRate: 8000, Channels: 1}).Supported())
+	assert.False(t, (&MediaFormat{Encoding: MulawEncoding, SampleRate: 8000, Channels: 2}).Supported())
+}
+
+func TestHandleCommandStartWithAgent(t *testing.T) {
+	call := newAgentCall(t, NewConfig())
+	app, session := call.app, call.session
+
+	transcripts := make(chan string, 1)
+
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"handle_transcript","id":"item_1","role":"assistant","text":"Hi there"}`}).
+		Run(func(args mock.Arguments) { transcripts <- args.Get(1).(*common.Message).Data.(string) }).
+		Return(nil, nil)
+
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"handle_function_call","arguments":"{}","name":"get_tasks"}`}).
+		Return(appResponse("openai.function_call_result", map[string]interface{}{"todos": []string{"Buy milk"}}), nil)
+
+	ai := call.start(t, OpenAIConfigData{Prompt: "Be nice"})
+
+	require.NotNil(t, call.executor.getAI(session))
+
+	msg, err := ai.WaitFor("session.update", 2*time.Second)
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), `"instructions":"Be nice"`)
+
+	t.Run("audio is sent to Twilio followed by a mark", func(t *testing.T) {
+		require.NoError(t, ai.SendAudioDelta("resp_1", "item_1", make([]byte, 160)))
+
+		media, err := call.conn.Read()
+		require.NoError(t, err)
+
+		var mediaMsg MediaMessage
+		require.NoError(t, json.Unmarshal(media, &mediaMsg))
+
+		assert.Equal(t, MediaEvent, mediaMsg.Event)
+		assert.Equal(t, "sm123", mediaMsg.StreamSID)
+
+		mark, err := call.conn.Read()
+		require.NoError(t, err)
+
+		var markMsg MarkMessage
+		require.NoError(t, json.Unmarshal(mark, &markMsg))
+
+		assert.Equal(t, MarkEvent, markMsg.Event)
+		assert.Equal(t, "ai-delta-item_1-1", markMsg.Mark.Name)
+	})
+
+	t.Run("transcripts are sent to the app", func(t *testing.T) {
+		require.NoError(t, ai.SendAudioTranscript("resp_1", "item_1", "Hi there"))
+
+		select {
+		case <-transcripts:
+		case <-time.After(2 * time.Second):
+			t.Fatal("handle_transcript hasn't been performed")
+		}
+	})
+
+	t.Run("function call results are sent back to the model", func(t *testing.T) {
+		require.NoError(t, ai.SendFunctionCall("resp_2", "item_2", "call_1", "get_tasks", "{}"))
+
+		msg, err := ai.WaitFor("conversation.item.create", 2*time.Second)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(msg), `"call_id":"call_1"`)
+		assert.Contains(t, string(msg), `Buy milk`)
+	})
+
+	t.Run("barge-in clears the playback and truncates the item", func(t *testing.T) {
+		require.NoError(t, ai.SendAudioDelta("resp_1", "item_1", make([]byte, 160)))
+
+		// Wait for media and mark
+		_, err := call.conn.Read()
+		require.NoError(t, err)
+		_, err = call.conn.Read()
+		require.NoError(t, err)
+
+		// Only the first chunk has been played, 160 bytes of μ-law audio is 20ms
+		err = call.executor.HandleCommand(session, &common.Message{Command: MarkEvent, Data: MarkPayload{Name: "ai-delta-item_1-1"}})
+		require.NoError(t, err)
+
+		require.NoError(t, ai.SendSpeechStarted("item_3"))
+
+		clear, err := call.conn.Read()
+		require.NoError(t, err)
+
+		var clearMsg ClearMessage
+		require.NoError(t, json.Unmarshal(clear, &clearMsg))
+		assert.Equal(t, ClearEvent, clearMsg.Event)
+
+		msg, err := ai.WaitFor("conversation.item.truncate", 2*time.Second)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(msg), `"item_id":"item_1"`)
+		assert.Contains(t, string(msg), `"audio_end_ms":20`)
+	})
+}
+
+func TestHandleFunctionCallTimeout(t *testing.T) {
+	for _, policy := range []string{RPCFailureError, RPCFailureIgnore} {
+		t.Run(policy, func(t *testing.T) {
+			c := NewConfig()
+			c.RPCTimeout = 100 * time.Millisecond
+			c.RPCFailurePolicy = policy
+
+			call := newAgentCall(t, c)
+
+			release := make(chan struct{})
+			performed := make(chan struct{})
+
+			call.app.
+				On("Perform", call.session, performAction("handle_function_call")).
+				Run(func(args mock.Arguments) {
+					<-release
+					close(performed)
+				}).
+				Return(appResponse(functionCallResultEvent, map[string]interface{}{"todos": []string{"Buy milk"}}), nil)
+
+			ai := call.start(t, OpenAIConfigData{})
+
+			_, err := ai.WaitFor("session.update", 2*time.Second)
+			require.NoError(t, err)
+
+			require.NoError(t, ai.SendFunctionCall("resp_1", "item_1", "call_1", "get_tasks", "{}"))
+
+			// Audio is delivered while the function call is being handled by the app
+			require.NoError(t, ai.SendAudioDelta("resp_2", "item_2", make([]byte, 160)))
+
+			media, err := call.conn.Read()
+			require.NoError(t, err)
+			assert.Contains(t, string(media), `"event":"media"`)
+
+			if policy == RPCFailureError {
+				msg, err := ai.WaitFor("conversation.item.create", 2*time.Second)
+				require.NoError(t, err)
+
+				assert.Contains(t, string(msg), `"call_id":"call_1"`)
+				assert.Contains(t, string(msg), `timed out`)
+			} else {
+				time.Sleep(2 * c.RPCTimeout)
+			}
+
+			close(release)
+			<-performed
+
+			// The late result is ignored
+			time.Sleep(200 * time.Millisecond)
+
+			outputs := 0
+
+			for _, msg := range ai.Received() {
+				if strings.Contains(string(msg), "function_call_output") {
+					outputs++
+				}
+			}
+
+			if policy == RPCFailureError {
+				assert.Equal(t, 1, outputs)
+			} else {
+				assert.Equal(t, 0, outputs)
+			}
+		})
+	}
+}
+
+func TestHandleCommandStartWithGreeting(t *testing.T) {
+	call := newAgentCall(t, NewConfig())
+
+	ai := call.start(t, OpenAIConfigData{Greeting: "Introduce yourself"})
+
+	msg, err := ai.WaitFor("response.create", 2*time.Second)
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), `"instructions":"Introduce yourself"`)
+}
+
+func TestHandleTimeout(t *testing.T) {
+	c := NewConfig()
+	c.Limits = Limits{MaxDuration: 100 * time.Millisecond}
+	c.TimeoutPromptWait = 2 * time.Second
+
+	call := newAgentCall(t, c)
+	session := call.session
+
+	timeouts := make(chan struct{}, 1)
+
+	call.app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"handle_timeout","reason":"max_duration"}`}).
+		Run(func(args mock.Arguments) { timeouts <- struct{}{} }).
+		Return(nil, nil)
+
+	ai := call.start(t, OpenAIConfigData{TimeoutPrompt: "Say goodbye"})
+
+	msg, err := ai.WaitFor("response.create", 2*time.Second)
+	require.NoError(t, err)
+	assert.Contains(t, string(msg), `"instructions":"Say goodbye"`)
+
+	select {
+	case <-timeouts:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handle_timeout hasn't been performed")
+	}
+
+	// The session is kept open until the closing prompt is played
+	require.NoError(t, ai.SendAudioDelta("resp_1", "item_1", make([]byte, 160)))
+
+	_, err = call.conn.Read()
+	require.NoError(t, err)
+	_, err = call.conn.Read()
+	require.NoError(t, err)
+
+	assert.False(t, session.IsClosed())
+
+	err = call.executor.HandleCommand(session, &common.Message{Command: MarkEvent, Data: MarkPayload{Name: "ai-delta-item_1-1"}})
+	require.NoError(t, err)
+
+	assert.Eventually(t, session.IsClosed, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestConcurrentRPCs(t *testing.T) {
+	c := NewConfig()
+	c.Limits = Limits{MaxDuration: 100 * time.Millisecond}
+
+	call := newAgentCall(t, c)
+	session := call.session
+
+	// Simulate the node merging the RPC response into the channel state
+	respond := func(action string, done chan struct{}) {
+		call.app.
+			On("Perform", session, performAction(action)).
+			Run(func(args mock.Arguments) {
+				for i := 0; i < 100; i++ {
+					session.MergeEnv(&common.SessionEnv{ChannelStates: &map[string]map[string]string{channelId(session): {responseState: `{"event":"noop"}`}}})
+				}
+
+				if done != nil {
+					close(done)
+				}
+			}).
+			Return(appResponse("noop", nil), nil)
+	}
+
+	transcribed := make(chan struct{})
+	timedOut := make(chan struct{})
+
+	respond("handle_usage", nil)
+	respond("handle_transcript", transcribed)
+	respond("handle_timeout", timedOut)
+
+	ai := call.start(t, OpenAIConfigData{})
+
+	// Make the transcript RPC run alongside the watchdog's timeout RPC
+	time.Sleep(90 * time.Millisecond)
+	require.NoError(t, ai.SendAudioTranscript("resp_1", "item_1", "Hello"))
+
+	for name, done := range map[string]chan struct{}{"handle_transcript": transcribed, "handle_timeout": timedOut} {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s hasn't been performed", name)
+		}
+	}
+}
+
+func TestHandleUsage(t *testing.T) {
+	m := metrics.NewMetrics(nil, 10, slog.Default())
+	RegisterMetrics(m)
+
+	c := NewConfig()
+	c.UsageReportInterval = 2
+
+	call := newAgentCall(t, c, WithInstrumenter(m))
+
+	reports := make(chan string, 10)
+
+	call.app.
+		On("Perform", call.session, performAction("handle_usage")).
+		Run(func(args mock.Arguments) { reports <- args.Get(1).(*common.Message).Data.(string) }).
+		Return(nil, nil)
+
+	ai := call.start(t, OpenAIConfigData{})
+
+	usage := map[string]interface{}{
+		"total_tokens":         30,
+		"input_tokens":         20,
+		"output_tokens":        10,
+		"input_token_details":  map[string]int{"cached_tokens": 5, "text_tokens": 12, "audio_tokens": 8},
+		"output_token_details": map[string]int{"text_tokens": 4, "audio_tokens": 6},
+	}
+
+	require.NoError(t, ai.SendResponseDone("resp_1", "completed", usage))
+	require.NoError(t, ai.SendResponseDone("resp_2", "completed", usage))
+
+	select {
+	case report := <-reports:
+		assert.Contains(t, report, `"final":false`)
+		assert.Contains(t, report, `"responses":2`)
+		assert.Contains(t, report, `"total_tokens":60`)
+		assert.Contains(t, report, `"cached_tokens":10`)
+	case <-time.After(2 * time.Second):
+		t.Fatal("handle_usage hasn't been performed")
+	}
+
+	assert.Equal(t, uint64(2), m.Counter(metricsResponses).Value())
+	assert.Equal(t, uint64(60), m.Counter(metricsTokens).Value())
+	assert.Equal(t, uint64(12), m.Counter(metricsOutputAudioTokens).Value())
+
+	require.NoError(t, ai.SendResponseDone("resp_3", "completed", usage))
+
+	assert.Eventually(t, func() bool { return m.Counter(metricsResponses).Value() == 3 }, 2*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, call.executor.Disconnect(call.session))
+
+	select {
+	case report := <-reports:
+		assert.Contains(t, report, `"final":true`)
+		assert.Contains(t, report, `"responses":3`)
+		assert.Contains(t, report, `"total_tokens":90`)
+	case <-time.After(2 * time.Second):
+		t.Fatal("final handle_usage hasn't been performed")
+	}
+}
+
+func TestDisconnectPerformsPendingRPCs(t *testing.T) {
+	call := newAgentCall(t, NewConfig())
+	app, session := call.app, call.session
+
+	var mu sync.Mutex
+	var calls []string
+
+	track := func(name string) func(mock.Arguments) {
+		return func(mock.Arguments) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			calls = append(calls, name)
+		}
+	}
+
+	received := make(chan struct{})
+
+	app.On("Disconnect", session).Run(track("disconnect")).Return(nil)
+	app.On("Perform", session, performAction("handle_usage")).Run(track("handle_usage")).Return(nil, nil)
+	app.
+		On("Perform", session, performAction("handle_transcript")).
+		Run(func(args mock.Arguments) {
+			close(received)
+			time.Sleep(100 * time.Millisecond)
+			track("handle_transcript")(args)
+		}).
+		Return(nil, nil)
+
+	ai := call.start(t, OpenAIConfigData{})
+
+	require.NoError(t, ai.SendAudioTranscript("resp_1", "item_1", "Bye"))
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handle_transcript hasn't been performed")
+	}
+
+	require.NoError(t, call.executor.Disconnect(session))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, []string{"handle_transcript", "handle_usage", "disconnect"}, calls)
+}
+
+func TestHangupFromPendingRPC(t *testing.T) {
+	c := NewConfig()
+	c.RPCTimeout = 5 * time.Second
+
+	call := newAgentCall(t, c)
+
+	disconnected := make(chan struct{})
+
+	call.app.On("Disconnect", call.session).Run(func(mock.Arguments) { close(disconnected) }).Return(nil)
+	call.app.
+		On("Perform", call.session, performAction("handle_function_call")).
+		Return(appResponse(hangupEvent, nil), nil)
+
+	ai := call.start(t, OpenAIConfigData{})
+
+	require.NoError(t, ai.SendFunctionCall("resp_1", "item_1", "call_1", "end_call", "{}"))
+
+	// Hanging up doesn't wait for the RPC queue it's been requested from
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("session hasn't been disconnected in time")
+	}
+
+	assert.True(t, call.session.IsClosed())
+}
+
+func TestHandleCommandDTMF(t *testing.T) {
+	call := newAgentCall(t, NewConfig())
+	app, session := call.app, call.session
+
+	disconnected := make(chan struct{})
+
+	app.On("Disconnect", session).Run(func(mock.Arguments) { close(disconnected) }).Return(nil)
+
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"handle_dtmf","digit":"1"}`}).
+		Return(appResponse(sayEvent, SayData{Text: "You have no tasks for today"}), nil)
+
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"handle_dtmf","digit":"2"}`}).
+		Return(appResponse(userMessageEvent, UserMessageData{Text: "The caller pressed 2"}), nil)
+
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"handle_dtmf","digit":"9"}`}).
+		Return(appResponse(hangupEvent, nil), nil)
+
+	ai := call.start(t, OpenAIConfigData{})
+
+	t.Run("say", func(t *testing.T) {
+		err := call.executor.HandleCommand(session, &common.Message{Command: DTMFEvent, Data: DTMFPayload{Digit: "1"}})
+		require.NoError(t, err)
+
+		// The current playback is interrupted
+		msg, err := call.conn.Read()
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"event":"clear","streamSid":"sm123"}`, string(msg))
+
+		msg, err = ai.WaitFor("response.create", 2*time.Second)
+		require.NoError(t, err)
+		assert.Contains(t, string(msg), "You have no tasks for today")
+	})
+
+	t.Run("user message", func(t *testing.T) {
+		err := call.executor.HandleCommand(session, &common.Message{Command: DTMFEvent, Data: DTMFPayload{Digit: "2"}})
+		require.NoError(t, err)
+
+		msg, err := ai.WaitFor("conversation.item.create", 2*time.Second)
+		require.NoError(t, err)
+		assert.Contains(t, string(msg), `"text":"The caller pressed 2"`)
+	})
+
+	t.Run("hangup", func(t *testing.T) {
+		err := call.executor.HandleCommand(session, &common.Message{Command: DTMFEvent, Data: DTMFPayload{Digit: "9"}})
+		require.NoError(t, err)
+
+		select {
+		case <-disconnected:
+		case <-time.After(2 * time.Second):
+			t.Fatal("session hasn't been disconnected")
+		}
+
+		assert.True(t, session.IsClosed())
+	})
+}
+
+func TestHandleCommandDTMFTurnKey(t *testing.T) {
+	for _, mode := range []string{agent.TurnDetectionManual, agent.TurnDetectionServerVAD} {
+		t.Run(mode, func(t *testing.T) {
+			c := NewConfig()
+			c.TurnKey = "#"
+
+			call := newAgentCall(t, c)
+			app, session := call.app, call.session
+
+			app.On("Perform", session, performAction("handle_dtmf")).Return(nil, nil)
+
+			ai := call.start(t, OpenAIConfigData{TurnDetection: &agent.TurnDetection{Mode: mode}})
+
+			_, err := ai.WaitFor("session.update", 2*time.Second)
+			require.NoError(t, err)
+
+			require.NoError(t, call.executor.HandleCommand(session, &common.Message{Command: DTMFEvent, Data: DTMFPayload{Digit: "#"}}))
+
+			if mode == agent.TurnDetectionServerVAD {
+				app.AssertCalled(t, "Perform", session, performAction("handle_dtmf"))
+				return
+			}
+
+			app.AssertNotCalled(t, "Perform", session, performAction("handle_dtmf"))
+
+			msg, err := call.conn.Read()
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"event":"clear","streamSid":"sm123"}`, string(msg))
+
+			_, err = ai.WaitFor("input_audio_buffer.commit", 2*time.Second)
+			require.NoError(t, err)
+
+			_, err = ai.WaitFor("response.create", 2*time.Second)
+			require.NoError(t, err)
+
+			// Other keys are handled by the app
+			require.NoError(t, call.executor.HandleCommand(session, &common.Message{Command: DTMFEvent, Data: DTMFPayload{Digit: "1"}}))
+
+			app.AssertCalled(t, "Perform", session, performAction("handle_dtmf"))
+		})
+	}
 }
 
 func TestHandleCommandMedia(t *testing.T) {
@@ -143,6 +789,74 @@ var (
 	sessionCounter = 1
 )
 
+// agentCall is a call handled by the executor with the agent connected to a fake OpenAI server
+type agentCall struct {
+	srv      *fake_openai.Server
+	app      *node_mocks.AppNode
+	executor *Executor
+	conn     mocks.MockConnection
+	session  *node.Session
+}
+
+func newAgentCall(t *testing.T, c *Config, opts ...ExecutorOption) *agentCall {
+	srv := fake_openai.NewServer()
+	t.Cleanup(srv.Close)
+
+	app := &node_mocks.AppNode{}
+	executor := NewExecutor(app, c, opts...)
+
+	conn := mocks.NewMockConnection()
+	session := buildSession(conn, NewMockNode(), executor, true)
+
+	return &agentCall{srv: srv, app: app, executor: executor, conn: conn, session: session}
+}
+
+// start stubs the common app calls (expectations registered before take precedence),
+// starts the stream with the agent configured by the app and returns the agent's connection
+func (call *agentCall) start(t *testing.T, conf OpenAIConfigData) *fake_openai.Conn {
+	app, session := call.app, call.session
+
+	conf.APIKey = "sk-test"
+	conf.URL = call.srv.URL()
+
+	app.On("Authenticated", session, mock.Anything)
+	app.On("Disconnect", session).Return(nil)
+	app.On("Perform", session, performAction("handle_usage")).Return(nil, nil)
+	app.
+		On("Subscribe", session, &common.Message{Identifier: channelId(session), Command: "subscribe"}).
+		Return(nil, nil)
+	app.
+		On("Perform", session, &common.Message{Identifier: channelId(session), Command: "message", Data: `{"action":"configure_openai"}`}).
+		Return(appResponse(configEvent, conf), nil)
+
+	start := StartPayload{AccountSID: "ac42", CallSID: "ca123", StreamSID: "sm123"}
+
+	require.NoError(t, call.executor.HandleCommand(session, &common.Message{Identifier: "sm123", Command: StartEvent, Data: start}))
+
+	// Sessions closed by the test are not disconnected twice
+	t.Cleanup(func() { session.Disconnect("test finished", ws.CloseNormalClosure) })
+
+	ai, err := call.srv.NextConn(2 * time.Second)
+	require.NoError(t, err)
+
+	return ai
+}
+
+func appResponse(event string, data interface{}) *common.CommandResult {
+	res := AppResponse{Event: event, Data: toJSON(data)}
+
+	return &common.CommandResult{IState: map[string]string{responseState: string(toJSON(res))}}
+}
+
+// performAction matches Perform calls with the specified action
+func performAction(action string) interface{} {
+	return mock.MatchedBy(func(msg *common.Message) bool {
+		data, ok := msg.Data.(string)
+
+		return ok && strings.Contains(data, `"action":"`+action+`"`)
+	})
+}
+
 func buildSession(conn node.Connection, n *node.Node, executor node.Executor, connected bool) *node.Session {
 	sessionCounter++
 	s := node.NewSession(n, conn, "ws://anycable.io/twilio", nil, strconv.Itoa(sessionCounter), node.WithEncoder(Encoder{}), node.WithExecutor(executor))
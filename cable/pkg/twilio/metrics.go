@@ -0,0 +1,52 @@
+package twilio
+
+import (
+	"github.com/anycable/anycable-go/metrics"
+
+	"github.com/palkan/twilio-ai-cable/pkg/agent"
+)
+
+const (
+	metricsResponses         = "agent_responses_total"
+	metricsTokens            = "agent_tokens_total"
+	metricsInputTokens       = "agent_input_tokens_total"
+	metricsOutputTokens      = "agent_output_tokens_total"
+	metricsCachedTokens      = "agent_cached_tokens_total"
+	metricsInputTextTokens   = "agent_input_text_tokens_total"
+	metricsInputAudioTokens  = "agent_input_audio_tokens_total"
+	metricsOutputTextTokens  = "agent_output_text_tokens_total"
+	metricsOutputAudioTokens = "agent_output_audio_tokens_total"
+)
+
+// RegisterMetrics registers agent usage metrics.
+// Must be called once before executors start tracking usage.
+func RegisterMetrics(m metrics.Instrumenter) {
+	m.RegisterCounter(metricsResponses, "The total number of agent responses")
+	m.RegisterCounter(metricsTokens, "The total number of tokens used by agents")
+	m.RegisterCounter(metricsInputTokens, "The total number of input tokens used by agents")
+	m.RegisterCounter(metricsOutputTokens, "The total number of output tokens used by agents")
+	m.RegisterCounter(metricsCachedTokens, "The total number of cached input tokens used by agents")
+	m.RegisterCounter(metricsInputTextTokens, "The total number of input text tokens used by agents")
+	m.RegisterCounter(metricsInputAudioTokens, "The total number of input audio tokens used by agents")
+	m.RegisterCounter(metricsOutputTextTokens, "The total number of output text tokens used by agents")
+	m.RegisterCounter(metricsOutputAudioTokens, "The total number of output audio tokens used by agents")
+}
+
+func trackUsage(m metrics.Instrumenter, u *agent.Usage) {
+	m.CounterIncrement(metricsResponses)
+
+	counterAdd(m, metricsTokens, u.TotalTokens)
+	counterAdd(m, metricsInputTokens, u.InputTokens)
+	counterAdd(m, metricsOutputTokens, u.OutputTokens)
+	counterAdd(m, metricsCachedTokens, u.InputTokenDetails.CachedTokens)
+	counterAdd(m, metricsInputTextTokens, u.InputTokenDetails.TextTokens)
+	counterAdd(m, metricsInputAudioTokens, u.InputTokenDetails.AudioTokens)
+	counterAdd(m, metricsOutputTextTokens, u.OutputTokenDetails.TextTokens)
+	counterAdd(m, metricsOutputAudioTokens, u.OutputTokenDetails.AudioTokens)
+}
+
+func counterAdd(m metrics.Instrumenter, name string, val int) {
+	if val > 0 {
+		m.CounterAdd(name, uint64(val))
+	}
+}
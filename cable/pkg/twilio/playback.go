@@ -0,0 +1,156 @@
+package twilio
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	markPrefix = "ai-delta-"
+	// μ-law 8kHz mono: 8 bytes per millisecond
+	bytesPerMs = 8
+)
+
+type pendingMark struct {
+	name     string
+	itemID   string
+	duration int
+}
+
+// Playback keeps track of the assistant audio sent to Twilio and
+// acknowledged via marks, so we know how much of each item the caller has heard.
+type Playback struct {
+	pending []*pendingMark
+	played  map[string]int
+	seq     int
+	// Closed (and replaced) every time chunks are played or dropped
+	changed chan struct{}
+
+	mu sync.Mutex
+}
+
+func NewPlayback() *Playback {
+	return &Playback{played: make(map[string]int), changed: make(chan struct{})}
+}
+
+// Enqueue registers a new chunk of audio (raw μ-law bytes count) for the item
+// and returns the name of the mark to send after it
+func (p *Playback) Enqueue(itemID string, size int) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.seq++
+
+	name := fmt.Sprintf("%s%s-%d", markPrefix, itemID, p.seq)
+
+	p.pending = append(p.pending, &pendingMark{name: name, itemID: itemID, duration: size / bytesPerMs})
+
+	return name
+}
+
+// Ack marks all the chunks up to the specified mark as played.
+// Returns false if the mark is unknown (e.g., was not sent by the agent or has been cleared)
+func (p *Playback) Ack(name string) bool {
+	if !strings.HasPrefix(name, markPrefix) {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	idx := -1
+
+	for i, mark := range p.pending {
+		if mark.name == name {
+			idx = i
+			break
+		}
+	}
+
+	if idx < 0 {
+		return false
+	}
+
+	for _, mark := range p.pending[:idx+1] {
+		p.played[mark.itemID] += mark.duration
+	}
+
+	p.pending = p.pending[idx+1:]
+	p.notify()
+
+	return true
+}
+
+// Played returns the number of milliseconds played for the item
+func (p *Playback) Played(itemID string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.played[itemID]
+}
+
+// Interrupt drops all the pending chunks and returns the item being played at the moment
+// along with the number of milliseconds actually played.
+// Returns an empty item ID if there is nothing being played.
+func (p *Playback) Interrupt() (string, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.pending) == 0 {
+		return "", 0
+	}
+
+	itemID := p.pending[0].itemID
+	p.pending = nil
+	p.notify()
+
+	return itemID, p.played[itemID]
+}
+
+// Enqueued returns the total number of chunks enqueued so far
+func (p *Playback) Enqueued() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.seq
+}
+
+// Pending returns the number of chunks sent but not played yet
+func (p *Playback) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.pending)
+}
+
+// WaitPlayed waits until the audio enqueued after the specified number of chunks (see Enqueued)
+// has been played (or dropped); returns false if it hasn't happened in time
+func (p *Playback) WaitPlayed(enqueued int, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		p.mu.Lock()
+		done := p.seq > enqueued && len(p.pending) == 0
+		changed := p.changed
+		p.mu.Unlock()
+
+		if done {
+			return true
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// notify must be called under the lock
+func (p *Playback) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
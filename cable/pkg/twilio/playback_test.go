@@ -0,0 +1,78 @@
+package twilio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlayback(t *testing.T) {
+	t.Run("tracks played audio per item", func(t *testing.T) {
+		p := NewPlayback()
+
+		m1 := p.Enqueue("item1", 800)
+		m2 := p.Enqueue("item1", 1600)
+		m3 := p.Enqueue("item2", 400)
+
+		assert.True(t, p.Ack(m1))
+		assert.Equal(t, 100, p.Played("item1"))
+
+		// Acking a later mark implies all the previous ones have been played
+		assert.True(t, p.Ack(m3))
+		assert.Equal(t, 300, p.Played("item1"))
+		assert.Equal(t, 50, p.Played("item2"))
+
+		assert.False(t, p.Ack(m2))
+	})
+
+	t.Run("ignores unknown marks", func(t *testing.T) {
+		p := NewPlayback()
+
+		p.Enqueue("item1", 800)
+
+		assert.False(t, p.Ack("greeting"))
+		assert.False(t, p.Ack("ai-delta-item1-42"))
+		assert.Equal(t, 0, p.Played("item1"))
+	})
+
+	t.Run("interrupt returns the item being played", func(t *testing.T) {
+		p := NewPlayback()
+
+		m1 := p.Enqueue("item1", 800)
+		m2 := p.Enqueue("item1", 800)
+		p.Enqueue("item1", 800)
+
+		p.Ack(m1)
+
+		itemID, played := p.Interrupt()
+
+		assert.Equal(t, "item1", itemID)
+		assert.Equal(t, 100, played)
+
+		// Marks sent back by Twilio after clear must not count
+		assert.False(t, p.Ack(m2))
+		assert.Equal(t, 100, p.Played("item1"))
+
+		itemID, _ = p.Interrupt()
+		assert.Equal(t, "", itemID)
+	})
+	t.Run("wait played", func(t *testing.T) {
+		p := NewPlayback()
+
+		p.Enqueue("item1", 800)
+		enqueued := p.Enqueued()
+
+		// Nothing new has been enqueued
+		assert.False(t, p.WaitPlayed(enqueued, 10*time.Millisecond))
+
+		m2 := p.Enqueue("item2", 800)
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			p.Ack(m2)
+		}()
+
+		assert.True(t, p.WaitPlayed(enqueued, time.Second))
+	})
+}
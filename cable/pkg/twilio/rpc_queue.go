@@ -0,0 +1,145 @@
+package twilio
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Function call RPC failure policies
+const (
+	// Send an error result to the agent, so it could tell the caller
+	RPCFailureError = "error"
+	// Do nothing (the agent doesn't respond until the next caller's turn)
+	RPCFailureIgnore = "ignore"
+)
+
+// ValidRPCFailurePolicy returns true if the policy is known
+func ValidRPCFailurePolicy(policy string) bool {
+	return policy == RPCFailureError || policy == RPCFailureIgnore
+}
+
+type rpcTask struct {
+	name string
+	run  func()
+	// Fires onTimeout when the task's deadline passes (whether it's running or still waiting in the queue)
+	deadline *time.Timer
+}
+
+// RPCQueue performs the app's RPC side effects (transcripts, function calls, etc.) one by one
+// in the background, so slow app actions don't block the agent events (e.g., audio deltas).
+// The tasks run strictly in order, one at a time. Each task's deadline starts when it's enqueued:
+// if the task isn't completed by then, onTimeout is called right away (e.g., to settle the function call),
+// even if the task is still waiting behind a slow one; the task itself is still performed later
+// (and the late result should be ignored by the task itself).
+type RPCQueue struct {
+	timeout time.Duration
+
+	queue  []*rpcTask
+	busy   bool
+	closed bool
+	// Closed when the queue becomes idle
+	idle chan struct{}
+	mu   sync.Mutex
+
+	log *slog.Logger
+}
+
+func NewRPCQueue(timeout time.Duration, l *slog.Logger) *RPCQueue {
+	return &RPCQueue{timeout: timeout, log: l}
+}
+
+// Enqueue adds a task to the queue; onTimeout (optional) is called if the task isn't completed within the timeout
+func (q *RPCQueue) Enqueue(name string, run func(), onTimeout func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		q.log.Debug("rpc queue is closed, task skipped", "task", name)
+		return
+	}
+
+	task := &rpcTask{name: name, run: run}
+
+	if q.timeout > 0 {
+		task.deadline = time.AfterFunc(q.timeout, func() {
+			q.log.Warn("rpc timed out", "task", name, "timeout", q.timeout)
+
+			if onTimeout != nil {
+				onTimeout()
+			}
+		})
+	}
+
+	q.queue = append(q.queue, task)
+
+	if !q.busy {
+		q.busy = true
+		q.idle = make(chan struct{})
+		go q.drain()
+	}
+}
+
+// Close stops accepting new tasks; the pending ones are still performed
+func (q *RPCQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+}
+
+// Wait blocks until all the pending tasks are performed or the timeout expires (0 means no limit);
+// returns false if the timeout has expired
+func (q *RPCQueue) Wait(timeout time.Duration) bool {
+	q.mu.Lock()
+
+	if !q.busy {
+		q.mu.Unlock()
+		return true
+	}
+
+	idle := q.idle
+	q.mu.Unlock()
+
+	if timeout <= 0 {
+		<-idle
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-idle:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (q *RPCQueue) drain() {
+	for {
+		q.mu.Lock()
+
+		if len(q.queue) == 0 {
+			q.busy = false
+			close(q.idle)
+			q.mu.Unlock()
+			return
+		}
+
+		task := q.queue[0]
+		q.queue = q.queue[1:]
+		q.mu.Unlock()
+
+		q.perform(task)
+	}
+}
+
+func (q *RPCQueue) perform(task *rpcTask) {
+	task.run()
+
+	if task.deadline != nil {
+		task.deadline.Stop()
+	}
+}
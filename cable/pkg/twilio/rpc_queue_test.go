@@ -0,0 +1,179 @@
+package twilio
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPCQueue(t *testing.T) {
+	t.Run("tasks are performed in order", func(t *testing.T) {
+		q := NewRPCQueue(time.Second, slog.Default())
+
+		var mu sync.Mutex
+		var performed []int
+
+		done := make(chan struct{})
+
+		for i := 0; i < 5; i++ {
+			q.Enqueue("task", func() {
+				// The first task is the slowest one
+				if i == 0 {
+					time.Sleep(50 * time.Millisecond)
+				}
+
+				mu.Lock()
+				performed = append(performed, i)
+				mu.Unlock()
+
+				if i == 4 {
+					close(done)
+				}
+			}, nil)
+		}
+
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("tasks haven't been performed")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, performed)
+	})
+
+	t.Run("calls timeout callback and keeps the order", func(t *testing.T) {
+		q := NewRPCQueue(50*time.Millisecond, slog.Default())
+
+		release := make(chan struct{})
+		timedOut := make(chan struct{})
+
+		var mu sync.Mutex
+		var performed []string
+
+		done := make(chan struct{})
+
+		q.Enqueue("slow transcript", func() {
+			<-release
+
+			mu.Lock()
+			performed = append(performed, "slow")
+			mu.Unlock()
+		}, func() { close(timedOut) })
+
+		q.Enqueue("next transcript", func() {
+			mu.Lock()
+			performed = append(performed, "next")
+			mu.Unlock()
+
+			close(done)
+		}, nil)
+
+		select {
+		case <-timedOut:
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout callback hasn't been called")
+		}
+
+		// The next task waits for the overdue one
+		select {
+		case <-done:
+			t.Fatal("next task has been performed before the overdue one")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(release)
+
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("next task hasn't been performed")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Equal(t, []string{"slow", "next"}, performed)
+	})
+
+	t.Run("deadline starts when the task is enqueued", func(t *testing.T) {
+		q := NewRPCQueue(50*time.Millisecond, slog.Default())
+
+		release := make(chan struct{})
+		defer close(release)
+
+		timedOut := make(chan struct{})
+
+		// The function call waits behind a hung transcript RPC
+		q.Enqueue("hung transcript", func() { <-release }, nil)
+		q.Enqueue("function call", func() {}, func() { close(timedOut) })
+
+		select {
+		case <-timedOut:
+		case <-time.After(time.Second):
+			t.Fatal("timeout callback hasn't been called for the queued task")
+		}
+	})
+
+	t.Run("pending tasks are performed after close", func(t *testing.T) {
+		q := NewRPCQueue(time.Second, slog.Default())
+
+		release := make(chan struct{})
+		pending := make(chan struct{})
+
+		q.Enqueue("first", func() { <-release }, nil)
+		q.Enqueue("pending", func() { close(pending) }, nil)
+
+		q.Close()
+
+		q.Enqueue("skipped", func() { t.Error("task must be skipped") }, nil)
+
+		close(release)
+
+		select {
+		case <-pending:
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "pending task hasn't been performed")
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	})
+	t.Run("wait for pending tasks", func(t *testing.T) {
+		q := NewRPCQueue(time.Second, slog.Default())
+
+		assert.True(t, q.Wait(time.Second))
+
+		release := make(chan struct{})
+		performed := make(chan struct{})
+
+		q.Enqueue("slow", func() { <-release }, nil)
+		q.Enqueue("last", func() { close(performed) }, nil)
+
+		q.Close()
+
+		assert.False(t, q.Wait(20*time.Millisecond))
+
+		close(release)
+
+		require.True(t, q.Wait(time.Second))
+
+		select {
+		case <-performed:
+		default:
+			t.Fatal("pending task hasn't been performed")
+		}
+	})
+}
+
+func TestValidRPCFailurePolicy(t *testing.T) {
+	assert.True(t, ValidRPCFailurePolicy(RPCFailureError))
+	assert.True(t, ValidRPCFailurePolicy(RPCFailureIgnore))
+	assert.False(t, ValidRPCFailurePolicy("retry"))
+	assert.False(t, ValidRPCFailurePolicy(""))
+}
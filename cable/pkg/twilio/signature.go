@@ -0,0 +1,163 @@
+package twilio
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" // nolint:gosec
+	"encoding/base64"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const SignatureHeader = "X-Twilio-Signature"
+
+// The header to pass the signed stream URL to the session (see SignatureValidator.Deferred)
+const StreamURLHeader = "X-Twilio-Stream-Url"
+
+// ComputeSignature calculates the request signature as Twilio does:
+// Base64-encoded HMAC-SHA1 of the full URL followed by the sorted POST parameters (if any).
+// See https://www.twilio.com/docs/usage/webhooks/webhooks-security
+func ComputeSignature(authToken string, fullURL string, params map[string]string) string {
+	var buf strings.Builder
+
+	buf.WriteString(fullURL)
+
+	keys := make([]string, 0, len(params))
+
+	for k := range params {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		buf.WriteString(k)
+		buf.WriteString(params[k])
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(buf.String()))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateSignature checks the signature against the URL.
+// Twilio may sign the URL with or without the port, so we check both variants (as Twilio SDKs do).
+func ValidateSignature(authToken string, fullURL string, signature string) bool {
+	if signature == "" {
+		return false
+	}
+
+	for _, variant := range urlVariants(fullURL) {
+		if hmac.Equal([]byte(ComputeSignature(authToken, variant, nil)), []byte(signature)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SignatureValidator rejects requests not signed by Twilio
+type SignatureValidator struct {
+	authToken string
+	// Public base URL of the server (e.g., wss://cable.example.com);
+	// if empty, it's inferred from the request
+	publicURL string
+	log       *slog.Logger
+}
+
+func NewSignatureValidator(authToken string, publicURL string, l *slog.Logger) *SignatureValidator {
+	return &SignatureValidator{authToken: authToken, publicURL: strings.TrimSuffix(publicURL, "/"), log: l}
+}
+
+// Middleware wraps the handler to validate requests before upgrading them
+func (v *SignatureValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fullURL := v.requestURL(r)
+
+		if !ValidateSignature(v.authToken, fullURL, r.Header.Get(SignatureHeader)) {
+			v.log.Debug("invalid Twilio signature", "url", fullURL)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Deferred only rejects requests without signatures and passes the stream URL to the session,
+// so the signature could be validated with the tenant's auth token when the stream starts
+func (v *SignatureValidator) Deferred(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SignatureHeader) == "" {
+			v.log.Debug("missing Twilio signature")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		r.Header.Set(StreamURLHeader, v.requestURL(r))
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v *SignatureValidator) requestURL(r *http.Request) string {
+	if v.publicURL != "" {
+		return v.publicURL + r.URL.RequestURI()
+	}
+
+	scheme := "ws"
+
+	if r.TLS != nil {
+		scheme = "wss"
+	}
+
+	switch r.Header.Get("X-Forwarded-Proto") {
+	case "https", "wss":
+		scheme = "wss"
+	case "http", "ws":
+		scheme = "ws"
+	}
+
+	host := r.Host
+
+	if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+
+	return scheme + "://" + host + r.URL.RequestURI()
+}
+
+func urlVariants(fullURL string) []string {
+	u, err := url.Parse(fullURL)
+
+	if err != nil {
+		return []string{fullURL}
+	}
+
+	variants := []string{fullURL}
+
+	if u.Port() != "" {
+		u.Host = u.Hostname()
+		variants = append(variants, u.String())
+	} else if port := defaultPort(u.Scheme); port != "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+		variants = append(variants, u.String())
+	}
+
+	return variants
+}
+
+func defaultPort(scheme string) string {
+	switch scheme {
+	case "https", "wss":
+		return "443"
+	case "http", "ws":
+		return "80"
+	}
+
+	return ""
+}
@@ -0,0 +1,113 @@
+package twilio
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeSignature(t *testing.T) {
+	// Example from https://www.twilio.com/docs/usage/webhooks/webhooks-security
+	params := map[string]string{
+		"CallSid": "CA1234567890ABCDE",
+		"Caller":  "+12349013030",
+		"Digits":  "1234",
+		"From":    "+12349013030",
+		"To":      "+18005551212",
+	}
+
+	assert.Equal(t, "0/KCTR6DLpKmkAf8muzZqo1nDgQ=", ComputeSignature("12345", "https://mycompany.com/myapp.php?foo=1&bar=2", params))
+}
+
+func TestValidateSignature(t *testing.T) {
+	signature := ComputeSignature("secret", "wss://cable.example.com/twilio", nil)
+
+	assert.True(t, ValidateSignature("secret", "wss://cable.example.com/twilio", signature))
+	assert.True(t, ValidateSignature("secret", "wss://cable.example.com:443/twilio", signature))
+	assert.False(t, ValidateSignature("other", "wss://cable.example.com/twilio", signature))
+	assert.False(t, ValidateSignature("secret", "wss://cable.example.com/vonage", signature))
+	assert.False(t, ValidateSignature("secret", "wss://cable.example.com/twilio", ""))
+
+	signatureWithPort := ComputeSignature("secret", "wss://cable.example.com:443/twilio", nil)
+
+	assert.True(t, ValidateSignature("secret", "wss://cable.example.com/twilio", signatureWithPort))
+}
+
+func TestSignatureValidatorMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	})
+
+	serve := func(v *SignatureValidator, r *http.Request) int {
+		w := httptest.NewRecorder()
+		v.Middleware(next).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	t.Run("inferred URL", func(t *testing.T) {
+		v := NewSignatureValidator("secret", "", slog.Default())
+
+		r := httptest.NewRequest("GET", "http://cable.example.com/twilio?token=42", nil)
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.Header.Set(SignatureHeader, ComputeSignature("secret", "wss://cable.example.com/twilio?token=42", nil))
+
+		assert.Equal(t, http.StatusSwitchingProtocols, serve(v, r))
+	})
+
+	t.Run("public URL", func(t *testing.T) {
+		v := NewSignatureValidator("secret", "wss://calls.example.com/", slog.Default())
+
+		r := httptest.NewRequest("GET", "http://localhost:8080/twilio", nil)
+		r.Header.Set(SignatureHeader, ComputeSignature("secret", "wss://calls.example.com/twilio", nil))
+
+		assert.Equal(t, http.StatusSwitchingProtocols, serve(v, r))
+	})
+
+	t.Run("invalid signature", func(t *testing.T) {
+		v := NewSignatureValidator("secret", "", slog.Default())
+
+		r := httptest.NewRequest("GET", "http://cable.example.com/twilio", nil)
+		r.Header.Set(SignatureHeader, ComputeSignature("other", "ws://cable.example.com/twilio", nil))
+
+		require.Equal(t, http.StatusForbidden, serve(v, r))
+	})
+
+	t.Run("missing signature", func(t *testing.T) {
+		v := NewSignatureValidator("secret", "", slog.Default())
+
+		r := httptest.NewRequest("GET", "http://cable.example.com/twilio", nil)
+
+		require.Equal(t, http.StatusForbidden, serve(v, r))
+	})
+}
+
+func TestSignatureValidatorDeferred(t *testing.T) {
+	var streamURL string
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		streamURL = r.Header.Get(StreamURLHeader)
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	})
+
+	v := NewSignatureValidator("", "", slog.Default())
+
+	r := httptest.NewRequest("GET", "http://cable.example.com/twilio", nil)
+	r.Header.Set(StreamURLHeader, "wss://evil.example.com/twilio")
+
+	w := httptest.NewRecorder()
+	v.Deferred(next).ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	r.Header.Set(SignatureHeader, "signature")
+
+	w = httptest.NewRecorder()
+	v.Deferred(next).ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusSwitchingProtocols, w.Code)
+	assert.Equal(t, "ws://cable.example.com/twilio", streamURL)
+}
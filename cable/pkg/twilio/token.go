@@ -0,0 +1,56 @@
+package twilio
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// The query parameter to pass the shared secret in (e.g., wss://cable.example.com/telnyx?token=...)
+const TokenParam = "token"
+
+// TokenValidator rejects requests without the shared secret.
+// It's used to authenticate carriers that don't sign stream requests (Telnyx, Vonage):
+// the token is passed either via the query parameter or the `Authorization: Bearer <token>` header.
+type TokenValidator struct {
+	token string
+	log   *slog.Logger
+}
+
+func NewTokenValidator(token string, l *slog.Logger) *TokenValidator {
+	return &TokenValidator{token: token, log: l}
+}
+
+// Middleware wraps the handler to validate requests before upgrading them
+func (v *TokenValidator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !v.valid(requestToken(r)) {
+			v.log.Debug("invalid stream token", "path", r.URL.Path)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v *TokenValidator) valid(token string) bool {
+	if v.token == "" || token == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(v.token), []byte(token)) == 1
+}
+
+func requestToken(r *http.Request) string {
+	if token := r.URL.Query().Get(TokenParam); token != "" {
+		return token
+	}
+
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	return ""
+}
@@ -0,0 +1,55 @@
+package twilio
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenValidatorMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	})
+
+	v := NewTokenValidator("secret", slog.Default())
+
+	serve := func(r *http.Request) int {
+		w := httptest.NewRecorder()
+		v.Middleware(next).ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for name, tc := range map[string]struct {
+		url    string
+		header string
+		status int
+	}{
+		"query":          {url: "http://cable.example.com/telnyx?token=secret", status: http.StatusSwitchingProtocols},
+		"header":         {url: "http://cable.example.com/vonage", header: "Bearer secret", status: http.StatusSwitchingProtocols},
+		"invalid query":  {url: "http://cable.example.com/telnyx?token=other", status: http.StatusForbidden},
+		"invalid header": {url: "http://cable.example.com/vonage", header: "Bearer other", status: http.StatusForbidden},
+		"missing":        {url: "http://cable.example.com/telnyx", status: http.StatusForbidden},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tc.url, nil)
+
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+
+			assert.Equal(t, tc.status, serve(r))
+		})
+	}
+
+	t.Run("empty token", func(t *testing.T) {
+		v := NewTokenValidator("", slog.Default())
+
+		w := httptest.NewRecorder()
+		v.Middleware(next).ServeHTTP(w, httptest.NewRequest("GET", "http://cable.example.com/telnyx?token=", nil))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
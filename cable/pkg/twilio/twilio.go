@@ -13,10 +13,36 @@ const (
 	DTMFEvent      = "dtmf"
 )
 
+// The only media format supported by Twilio bidirectional streams (and by the agent)
+const (
+	MulawEncoding   = "audio/x-mulaw"
+	MulawSampleRate = 8000
+)
+
+type MediaFormat struct {
+	Encoding   string `json:"encoding"`
+	SampleRate int    `json:"sampleRate"`
+	Channels   int    `json:"channels"`
+}
+
+// Supported returns true if the format is 8kHz mono μ-law (missing format is considered μ-law, too)
+func (f *MediaFormat) Supported() bool {
+	if f == nil {
+		return true
+	}
+
+	return f.Encoding == MulawEncoding && f.SampleRate == MulawSampleRate && f.Channels <= 1
+}
+
 type StartPayload struct {
 	AccountSID string `json:"accountSid"`
 	StreamSID  string `json:"streamSid"`
 	CallSID    string `json:"callSid"`
+	// Tracks streamed by Twilio ("inbound", "outbound")
+	Tracks      []string     `json:"tracks,omitempty"`
+	MediaFormat *MediaFormat `json:"mediaFormat,omitempty"`
+	// Parameters passed via <Parameter> in TwiML
+	CustomParameters map[string]string `json:"customParameters,omitempty"`
 }
 
 func (p *StartPayload) ToJSON() ([]byte, error) {
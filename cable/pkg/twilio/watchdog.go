@@ -0,0 +1,190 @@
+package twilio
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/palkan/twilio-ai-cable/internal/g711"
+)
+
+// Timeout reasons passed to the handle_timeout RPC action
+const (
+	TimeoutMaxDuration = "max_duration"
+	TimeoutSilence     = "silence"
+	TimeoutIdle        = "idle"
+
+	// Mean absolute amplitude of 16-bit PCM to consider a frame a voice (roughly -30 dBFS)
+	voiceThreshold = 1000
+)
+
+// Limits define how long a call can last (zero values mean no limit)
+type Limits struct {
+	// Maximum call duration
+	MaxDuration time.Duration
+	// Maximum time the caller can stay silent
+	MaxSilence time.Duration
+	// Maximum time with no activity from both sides (no speech, no bot's audio, no DTMF)
+	MaxIdle time.Duration
+}
+
+func (l Limits) Enabled() bool {
+	return l.MaxDuration > 0 || l.MaxSilence > 0 || l.MaxIdle > 0
+}
+
+// Watchdog tracks the call activity and triggers the callback (only once) when a limit is reached
+type Watchdog struct {
+	limits    Limits
+	onTimeout func(reason string)
+
+	startedAt    time.Time
+	lastSpeech   time.Time
+	lastActivity time.Time
+
+	timer *time.Timer
+	done  bool
+
+	mu sync.Mutex
+}
+
+func NewWatchdog(l Limits, onTimeout func(reason string)) *Watchdog {
+	return &Watchdog{limits: l, onTimeout: onTimeout}
+}
+
+// Start starts tracking the limits
+func (w *Watchdog) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+
+	w.startedAt = now
+	w.lastSpeech = now
+	w.lastActivity = now
+
+	w.schedule(now)
+}
+
+// Speech must be called when the caller speaks
+func (w *Watchdog) Speech() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+
+	w.lastSpeech = now
+	w.lastActivity = now
+}
+
+// Activity must be called on any other call activity (e.g., bot's speech or DTMF)
+func (w *Watchdog) Activity() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastActivity = time.Now()
+}
+
+// Stop stops tracking the limits
+func (w *Watchdog) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.done = true
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// We don't reset the timer on every activity event (that happens every 20ms while speaking);
+// instead, we re-check the deadlines when the timer fires and reschedule if needed
+func (w *Watchdog) check() {
+	w.mu.Lock()
+
+	if w.done {
+		w.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	reason := w.expired(now)
+
+	if reason == "" {
+		w.schedule(now)
+		w.mu.Unlock()
+		return
+	}
+
+	w.done = true
+	w.mu.Unlock()
+
+	w.onTimeout(reason)
+}
+
+func (w *Watchdog) expired(now time.Time) string {
+	for _, d := range w.deadlines() {
+		if !d.at.After(now) {
+			return d.reason
+		}
+	}
+
+	return ""
+}
+
+func (w *Watchdog) schedule(now time.Time) {
+	var next time.Time
+
+	for _, d := range w.deadlines() {
+		if next.IsZero() || d.at.Before(next) {
+			next = d.at
+		}
+	}
+
+	if next.IsZero() {
+		return
+	}
+
+	if w.timer == nil {
+		w.timer = time.AfterFunc(next.Sub(now), w.check)
+	} else {
+		w.timer.Reset(next.Sub(now))
+	}
+}
+
+type deadline struct {
+	reason string
+	at     time.Time
+}
+
+func (w *Watchdog) deadlines() []deadline {
+	var res []deadline
+
+	if w.limits.MaxDuration > 0 {
+		res = append(res, deadline{TimeoutMaxDuration, w.startedAt.Add(w.limits.MaxDuration)})
+	}
+
+	if w.limits.MaxSilence > 0 {
+		res = append(res, deadline{TimeoutSilence, w.lastSpeech.Add(w.limits.MaxSilence)})
+	}
+
+	if w.limits.MaxIdle > 0 {
+		res = append(res, deadline{TimeoutIdle, w.lastActivity.Add(w.limits.MaxIdle)})
+	}
+
+	return res
+}
+
+// hasVoice returns true if the μ-law frame is loud enough to be a speech
+func hasVoice(ulaw []byte) bool {
+	if len(ulaw) == 0 {
+		return false
+	}
+
+	var sum float64
+
+	for _, b := range ulaw {
+		sum += math.Abs(float64(g711.DecodeUlawFrame(b)))
+	}
+
+	return sum/float64(len(ulaw)) > voiceThreshold
+}
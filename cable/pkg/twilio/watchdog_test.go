@@ -0,0 +1,93 @@
+package twilio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/palkan/twilio-ai-cable/internal/g711"
+)
+
+func TestWatchdog(t *testing.T) {
+	t.Run("max duration", func(t *testing.T) {
+		reasons := make(chan string, 1)
+		wd := NewWatchdog(Limits{MaxDuration: 50 * time.Millisecond}, func(reason string) { reasons <- reason })
+		wd.Start()
+		defer wd.Stop()
+
+		assert.Equal(t, TimeoutMaxDuration, receiveReason(t, reasons))
+	})
+
+	t.Run("silence is reset by speech", func(t *testing.T) {
+		reasons := make(chan string, 1)
+		wd := NewWatchdog(Limits{MaxSilence: 100 * time.Millisecond}, func(reason string) { reasons <- reason })
+
+		started := time.Now()
+		wd.Start()
+		defer wd.Stop()
+
+		time.Sleep(60 * time.Millisecond)
+		wd.Speech()
+		// Other activity doesn't count as the caller's speech
+		wd.Activity()
+
+		assert.Equal(t, TimeoutSilence, receiveReason(t, reasons))
+		assert.GreaterOrEqual(t, time.Since(started), 160*time.Millisecond)
+	})
+
+	t.Run("idle is reset by any activity", func(t *testing.T) {
+		reasons := make(chan string, 1)
+		wd := NewWatchdog(Limits{MaxIdle: 100 * time.Millisecond, MaxSilence: time.Second}, func(reason string) { reasons <- reason })
+
+		started := time.Now()
+		wd.Start()
+		defer wd.Stop()
+
+		time.Sleep(60 * time.Millisecond)
+		wd.Activity()
+
+		assert.Equal(t, TimeoutIdle, receiveReason(t, reasons))
+		assert.GreaterOrEqual(t, time.Since(started), 160*time.Millisecond)
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		reasons := make(chan string, 1)
+		wd := NewWatchdog(Limits{MaxDuration: 20 * time.Millisecond}, func(reason string) { reasons <- reason })
+		wd.Start()
+		wd.Stop()
+
+		select {
+		case reason := <-reasons:
+			t.Fatalf("unexpected timeout: %s", reason)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
+
+func TestHasVoice(t *testing.T) {
+	silence := make([]byte, 160)
+	loud := make([]byte, 160)
+
+	for i := range silence {
+		silence[i] = g711.EncodeUlawFrame(int16(i % 10))
+		loud[i] = g711.EncodeUlawFrame(int16(8000 * (1 - 2*(i%2))))
+	}
+
+	assert.False(t, hasVoice(silence))
+	assert.True(t, hasVoice(loud))
+	assert.False(t, hasVoice(nil))
+}
+
+func receiveReason(t *testing.T, ch chan string) string {
+	t.Helper()
+
+	select {
+	case reason := <-ch:
+		return reason
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout hasn't been triggered")
+	}
+
+	return ""
+}
@@ -0,0 +1,55 @@
+package vonage
+
+import (
+	"time"
+
+	"github.com/anycable/anycable-go/ws"
+	"github.com/gorilla/websocket"
+)
+
+// Binary frames are prefixed with this byte when passed to the encoder,
+// so it can distinguish audio from JSON messages
+const binaryTag = 0x00
+
+// Connection wraps a WebSocket connection to tag binary frames and
+// to split outgoing audio into 20ms frames (as Vonage expects)
+type Connection struct {
+	*ws.Connection
+
+	conn   *websocket.Conn
+	stream *Stream
+}
+
+func NewConnection(conn *websocket.Conn, stream *Stream) *Connection {
+	return &Connection{Connection: ws.NewConnection(conn), conn: conn, stream: stream}
+}
+
+func (c *Connection) Read() ([]byte, error) {
+	mt, message, err := c.conn.ReadMessage()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if mt == websocket.BinaryMessage {
+		return append([]byte{binaryTag}, message...), nil
+	}
+
+	return message, nil
+}
+
+func (c *Connection) WriteBinary(msg []byte, deadline time.Time) error {
+	size := c.stream.FrameSize()
+
+	for len(msg) > 0 {
+		n := min(size, len(msg))
+
+		if err := c.Connection.WriteBinary(msg[:n], deadline); err != nil {
+			return err
+		}
+
+		msg = msg[n:]
+	}
+
+	return nil
+}
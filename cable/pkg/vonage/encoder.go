@@ -0,0 +1,145 @@
+package vonage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/encoders"
+	"github.com/anycable/anycable-go/ws"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+// Encoder converts Vonage WebSocket messages from/to Twilio Media Streams commands,
+// so we can reuse the Twilio executor.
+// Unlike other encoders, it's stateful (audio format is negotiated per connection).
+type Encoder struct {
+	stream *Stream
+}
+
+var _ encoders.Encoder = (*Encoder)(nil)
+
+const vonageEncoderID = "vonage"
+
+func NewEncoder(stream *Stream) *Encoder {
+	return &Encoder{stream: stream}
+}
+
+func (*Encoder) ID() string {
+	return vonageEncoderID
+}
+
+func (enc *Encoder) Encode(msg encoders.EncodedMessage) (*ws.SentFrame, error) {
+	r, ok := msg.(*common.Reply)
+
+	// Ignore pings, disconnects, etc.
+	if !ok {
+		return nil, nil
+	}
+
+	var action *ActionMessage
+
+	switch r.Type {
+	case twilio.MediaEvent:
+		media, ok := r.Message.(twilio.MediaPayload)
+
+		if !ok {
+			return nil, fmt.Errorf("malformed media message: %v", r.Message)
+		}
+
+		ulaw, err := base64.StdEncoding.DecodeString(media.Payload)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &ws.SentFrame{FrameType: ws.BinaryFrame, Payload: enc.stream.FromUlaw(ulaw)}, nil
+	case twilio.MarkEvent:
+		mark, ok := r.Message.(twilio.MarkPayload)
+
+		if !ok {
+			return nil, fmt.Errorf("malformed mark message: %v", r.Message)
+		}
+
+		// Vonage sends the notify payload back when the preceding audio has been played
+		action = &ActionMessage{Action: NotifyAction, Payload: &NotifyPayload{Mark: mark.Name}}
+	case twilio.ClearEvent:
+		action = &ActionMessage{Action: ClearAction}
+	default:
+		// Transmissions, confirmations, etc. are not supported
+		return nil, nil
+	}
+
+	b, err := json.Marshal(action)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ws.SentFrame{FrameType: ws.TextFrame, Payload: b}, nil
+}
+
+func (*Encoder) EncodeTransmission(raw string) (*ws.SentFrame, error) {
+	return nil, nil
+}
+
+func (enc *Encoder) Decode(raw []byte) (*common.Message, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	if raw[0] == binaryTag {
+		return &common.Message{
+			Command: twilio.MediaEvent,
+			Data:    twilio.MediaPayload{Track: "inbound", Payload: base64.StdEncoding.EncodeToString(enc.stream.ToUlaw(raw[1:]))},
+		}, nil
+	}
+
+	var typed struct {
+		Event string `json:"event"`
+	}
+
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return nil, err
+	}
+
+	switch typed.Event {
+	case ConnectedEvent:
+		var connected ConnectedMessage
+
+		if err := json.Unmarshal(raw, &connected); err != nil {
+			return nil, err
+		}
+
+		enc.stream.SetSampleRate(connected.SampleRate())
+
+		id := connected.CallID()
+
+		return &common.Message{
+			Command:    twilio.StartEvent,
+			Identifier: id,
+			Data:       twilio.StartPayload{CallSID: id, StreamSID: id},
+		}, nil
+	case DTMFEvent:
+		var dtmf DTMFMessage
+
+		if err := json.Unmarshal(raw, &dtmf); err != nil {
+			return nil, err
+		}
+
+		return &common.Message{Command: twilio.DTMFEvent, Data: twilio.DTMFPayload{Digit: dtmf.Digit}}, nil
+	case NotifyEvent:
+		var notify NotifyMessage
+
+		if err := json.Unmarshal(raw, &notify); err != nil {
+			return nil, err
+		}
+
+		return &common.Message{Command: twilio.MarkEvent, Data: twilio.MarkPayload{Name: notify.Payload.Mark}}, nil
+	}
+
+	// Ignore unknown events
+	return nil, nil
+}
@@ -0,0 +1,102 @@
+package vonage
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+func TestEncoderDecode(t *testing.T) {
+	stream := NewStream()
+	coder := NewEncoder(stream)
+
+	t.Run("connected", func(t *testing.T) {
+		raw := `{"event":"websocket:connected","content-type":"audio/l16;rate=16000","call_uuid":"63f61863-4a51"}`
+
+		msg, err := coder.Decode([]byte(raw))
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.StartEvent, msg.Command)
+		assert.Equal(t, "63f61863-4a51", msg.Data.(twilio.StartPayload).CallSID)
+		assert.Equal(t, 16000, stream.SampleRate())
+	})
+
+	t.Run("audio", func(t *testing.T) {
+		// 20ms of 16kHz L16
+		msg, err := coder.Decode(append([]byte{binaryTag}, make([]byte, 640)...))
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.MediaEvent, msg.Command)
+
+		ulaw, err := base64.StdEncoding.DecodeString(msg.Data.(twilio.MediaPayload).Payload)
+		require.NoError(t, err)
+		assert.Len(t, ulaw, 160)
+	})
+
+	t.Run("dtmf", func(t *testing.T) {
+		msg, err := coder.Decode([]byte(`{"event":"websocket:dtmf","digit":"5","duration":260}`))
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.DTMFEvent, msg.Command)
+		assert.Equal(t, "5", msg.Data.(twilio.DTMFPayload).Digit)
+	})
+
+	t.Run("notify", func(t *testing.T) {
+		msg, err := coder.Decode([]byte(`{"event":"websocket:notify","payload":{"mark":"ai-delta-1"}}`))
+
+		require.NoError(t, err)
+		assert.Equal(t, twilio.MarkEvent, msg.Command)
+		assert.Equal(t, "ai-delta-1", msg.Data.(twilio.MarkPayload).Name)
+	})
+}
+
+func TestEncoderEncode(t *testing.T) {
+	stream := NewStream()
+	coder := NewEncoder(stream)
+
+	t.Run("media", func(t *testing.T) {
+		msg := &common.Reply{Type: twilio.MediaEvent, Message: twilio.MediaPayload{Payload: base64.StdEncoding.EncodeToString(make([]byte, 160))}}
+
+		actual, err := coder.Encode(msg)
+
+		require.NoError(t, err)
+		assert.Equal(t, ws.BinaryFrame, actual.FrameType)
+		// 20ms of 16kHz L16
+		assert.Len(t, actual.Payload, 640)
+	})
+
+	t.Run("media 8kHz", func(t *testing.T) {
+		stream.SetSampleRate(8000)
+		defer stream.SetSampleRate(16000)
+
+		msg := &common.Reply{Type: twilio.MediaEvent, Message: twilio.MediaPayload{Payload: base64.StdEncoding.EncodeToString(make([]byte, 160))}}
+
+		actual, err := coder.Encode(msg)
+
+		require.NoError(t, err)
+		assert.Len(t, actual.Payload, 320)
+	})
+
+	t.Run("mark", func(t *testing.T) {
+		msg := &common.Reply{Type: twilio.MarkEvent, Message: twilio.MarkPayload{Name: "ai-delta-1"}}
+
+		actual, err := coder.Encode(msg)
+
+		require.NoError(t, err)
+		assert.Equal(t, ws.TextFrame, actual.FrameType)
+		assert.JSONEq(t, `{"action":"notify","payload":{"mark":"ai-delta-1"}}`, string(actual.Payload))
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		actual, err := coder.Encode(&common.Reply{Type: twilio.ClearEvent})
+
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"action":"clear"}`, string(actual.Payload))
+	})
+}
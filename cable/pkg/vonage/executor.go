@@ -0,0 +1,53 @@
+package vonage
+
+import (
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/node"
+
+	"github.com/palkan/twilio-ai-cable/pkg/twilio"
+)
+
+// Executor drives Vonage WebSocket calls through the Twilio executor
+type Executor struct {
+	twilio *twilio.Executor
+}
+
+var _ twilio.CommandExecutor = (*Executor)(nil)
+
+func NewExecutor(node node.AppNode, c *twilio.Config, opts ...twilio.ExecutorOption) *Executor {
+	// Vonage calls have no Twilio account information
+	conf := *c
+	conf.AccountSID = ""
+
+	return &Executor{twilio: twilio.NewExecutor(node, &conf, opts...)}
+}
+
+func (ex *Executor) HandleCommand(s *node.Session, msg *common.Message) error {
+	if msg.Command == twilio.StartEvent {
+		// The websocket:connected message is both "connected" and "start" in Twilio terms
+		if !s.Connected {
+			if err := ex.twilio.HandleCommand(s, &common.Message{Command: twilio.ConnectedEvent}); err != nil {
+				return err
+			}
+		}
+
+		// Fallback to the session ID if no call UUID provided via custom headers
+		if start, ok := msg.Data.(twilio.StartPayload); ok && start.CallSID == "" {
+			msg = &common.Message{
+				Command:    msg.Command,
+				Identifier: s.GetID(),
+				Data:       twilio.StartPayload{CallSID: s.GetID(), StreamSID: s.GetID()},
+			}
+		}
+	}
+
+	return ex.twilio.HandleCommand(s, msg)
+}
+
+func (ex *Executor) HandleAppCommand(s *node.Session, cmd *twilio.AppResponse) {
+	ex.twilio.HandleAppCommand(s, cmd)
+}
+
+func (ex *Executor) Disconnect(s *node.Session) error {
+	return ex.twilio.Disconnect(s)
+}
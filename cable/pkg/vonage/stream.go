@@ -0,0 +1,51 @@
+package vonage
+
+import (
+	"sync"
+
+	"github.com/palkan/twilio-ai-cable/internal/wav"
+)
+
+// Stream holds the audio format of a single Vonage WebSocket connection.
+// It's shared by the encoder (which converts audio) and the connection (which splits it into frames).
+type Stream struct {
+	rate int
+	mu   sync.RWMutex
+}
+
+func NewStream() *Stream {
+	return &Stream{rate: defaultSampleRate}
+}
+
+func (st *Stream) SetSampleRate(rate int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.rate = rate
+}
+
+func (st *Stream) SampleRate() int {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	return st.rate
+}
+
+// FrameSize returns the number of bytes in a 20ms L16 frame
+func (st *Stream) FrameSize() int {
+	return st.SampleRate() / 50 * 2
+}
+
+// ToUlaw converts L16 audio of the stream to 8kHz μ-law
+func (st *Stream) ToUlaw(l16 []byte) []byte {
+	f := wav.PCMFormat(1, uint32(st.SampleRate())) // #nosec G115
+
+	ulaw, _ := wav.ToMulaw(&f, l16)
+
+	return ulaw
+}
+
+// FromUlaw converts 8kHz μ-law audio to L16 of the stream
+func (st *Stream) FromUlaw(ulaw []byte) []byte {
+	return wav.FromMulaw(ulaw, st.SampleRate())
+}
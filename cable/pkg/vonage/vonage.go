@@ -0,0 +1,75 @@
+package vonage
+
+import (
+	"strconv"
+	"strings"
+)
+
+// See https://developer.vonage.com/en/voice/voice-api/concepts/websockets
+const (
+	ConnectedEvent = "websocket:connected"
+	DTMFEvent      = "websocket:dtmf"
+	NotifyEvent    = "websocket:notify"
+
+	NotifyAction = "notify"
+	ClearAction  = "clear"
+
+	defaultSampleRate = 16000
+)
+
+// ConnectedMessage is the first (text) message sent by Vonage.
+// It also contains custom headers specified in the NCCO (we look for the call UUID there).
+type ConnectedMessage struct {
+	Event            string `json:"event"`
+	ContentType      string `json:"content-type"`
+	UUID             string `json:"uuid,omitempty"`
+	CallUUID         string `json:"call_uuid,omitempty"`
+	ConversationUUID string `json:"conversation_uuid,omitempty"`
+}
+
+// CallID returns the best available call identifier
+func (m *ConnectedMessage) CallID() string {
+	for _, id := range []string{m.CallUUID, m.UUID, m.ConversationUUID} {
+		if id != "" {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// SampleRate parses the sample rate from the content type (e.g., "audio/l16;rate=16000")
+func (m *ConnectedMessage) SampleRate() int {
+	for _, part := range strings.Split(m.ContentType, ";") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+
+		if ok && key == "rate" {
+			if rate, err := strconv.Atoi(val); err == nil && rate > 0 {
+				return rate
+			}
+		}
+	}
+
+	return defaultSampleRate
+}
+
+type DTMFMessage struct {
+	Event    string `json:"event"`
+	Digit    string `json:"digit"`
+	Duration int    `json:"duration"`
+}
+
+type NotifyPayload struct {
+	Mark string `json:"mark"`
+}
+
+type NotifyMessage struct {
+	Event   string        `json:"event"`
+	Payload NotifyPayload `json:"payload"`
+}
+
+// ActionMessage is sent to Vonage to control the playback
+type ActionMessage struct {
+	Action  string         `json:"action"`
+	Payload *NotifyPayload `json:"payload,omitempty"`
+}